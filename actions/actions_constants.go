@@ -8,3 +8,16 @@ const SetHigh = "sethigh"
 const SetLow = "setlow"
 const GetChart = "chart"
 const Sleep = "sleep"
+const Export = "export"
+const Timezone = "timezone"
+const Buy = "buy"
+const Sell = "sell"
+const Portfolio = "portfolio"
+const Import = "import"
+const Gains = "gains"
+const Watch = "watch"
+const Paper = "paper"
+const DCA = "dca"
+const WhatIf = "whatif"
+const Alert = "alert"
+const Live = "live"
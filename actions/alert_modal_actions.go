@@ -0,0 +1,406 @@
+package actions
+
+import (
+	"crypto-bot/render"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// AlertModalCallback Callback ID of the modal that creates and edits alerts
+const AlertModalCallback = "alert_modal"
+
+// EditAlertAction Button that opens the alert modal on an existing alert, its value is the alert ID
+const EditAlertAction = "edit_alert"
+
+// AlertCryptoAction The coin picker of the alert modal, picking a coin updates the current price hint
+const AlertCryptoAction = "alert_crypto"
+
+// Blocks of the alert modal, the value of each one is read by the action with the same ID
+const (
+	alertCryptoBlock    = "crypto"
+	alertRuleBlock      = "rule"
+	alertThresholdBlock = "threshold"
+	alertExpiryBlock    = "expiry"
+	alertDeliveryBlock  = "delivery"
+)
+
+// Delivery options of an alert
+const (
+	deliverToChannel = "channel"
+	deliverToDM      = "dm"
+)
+
+// keepExpiry Expiry option of an edited alert that keeps the expiry it has
+const keepExpiry = "keep"
+
+// alertExpiries Expiry options of an alert, in the order the modal shows them
+var alertExpiries = []struct {
+	Value    string
+	Text     string
+	Duration time.Duration
+}{
+	{"never", "Never", 0},
+	{"24h", "In 24 hours", 24 * time.Hour},
+	{"7d", "In 7 days", 7 * 24 * time.Hour},
+	{"30d", "In 30 days", 30 * 24 * time.Hour},
+}
+
+const (
+	// modalPriceAge How long a price is used by the modal before it is fetched again
+	modalPriceAge = time.Minute
+	// hintPriceWait How long the modal waits for the price of its hint, Slack drops trigger IDs after 3 seconds
+	hintPriceWait = time.Second
+	// savePriceWait How long saving an alert waits for the price, it runs after the submission was acknowledged
+	savePriceWait = 10 * time.Second
+)
+
+// modalPrice A price fetched for the alert modal
+type modalPrice struct {
+	value float64
+	at    time.Time
+	// ready Closed once the price was fetched
+	ready chan struct{}
+}
+
+// modalPrices Latest price of each crypto, so the modal doesn't wait for the price provider in Slack's event loop
+var (
+	modalPricesMutex sync.Mutex
+	modalPrices      = make(map[string]*modalPrice)
+)
+
+// alertForm What the alert modal shows or what was submitted. An empty ID creates a new alert
+type alertForm struct {
+	ID        string
+	Crypto    string
+	Rule      string
+	Threshold string
+	Expiry    string
+	// Expires Expiry of the edited alert, shown in the keep option
+	Expires  string
+	Delivery string
+	// Channel Where the modal was opened, the confirmation is posted there
+	Channel string
+	// Location Timezone the dates are shown in
+	Location *time.Location
+}
+
+// NewAlertModal The modal to create an alert, with the crypto already picked if it is a known one
+func NewAlertModal(crypto string, channel string) slack.ModalViewRequest {
+	abbreviatedCryptoName, found := utils.GetAbbreviatedCryptoName(crypto)
+	if !found {
+		abbreviatedCryptoName = ""
+	}
+	return alertModal(&alertForm{
+		Crypto:   abbreviatedCryptoName,
+		Rule:     "highLimit",
+		Expiry:   alertExpiries[0].Value,
+		Delivery: deliverToDM,
+		Channel:  channel,
+	})
+}
+
+// EditAlertModal The modal to edit an active alert of the user, with its expiry shown in loc
+func EditAlertModal(userName string, id string, channel string, loc *time.Location) (slack.ModalViewRequest, error) {
+	rule, err := utils.GetActiveRule(userName, id)
+	if err != nil {
+		return slack.ModalViewRequest{}, err
+	}
+	form := &alertForm{
+		ID:        rule.ID(),
+		Crypto:    rule.Crypto(),
+		Rule:      rule.Rule().String(),
+		Threshold: strconv.FormatFloat(rule.Price(), 'f', -1, 64),
+		Expiry:    alertExpiries[0].Value,
+		Delivery:  deliverToChannel,
+		Channel:   channel,
+		Location:  loc,
+	}
+	if expires, found := rule.ExpiresAt(); found {
+		form.Expiry = keepExpiry
+		form.Expires = expires.Format(time.RFC3339)
+	}
+	if rule.Direct() {
+		form.Delivery = deliverToDM
+	}
+	return alertModal(form), nil
+}
+
+// RefreshAlertModal The modal again after a coin was picked, so the hint shows the price of the new coin. The dates
+// are shown in loc
+func RefreshAlertModal(view slack.View, loc *time.Location) slack.ModalViewRequest {
+	form := readAlertForm(view)
+	form.Location = loc
+	return alertModal(form)
+}
+
+// ValidateAlertModal Checks the submitted alert, returning the errors to show under each block. It must answer within
+// the 3 seconds Slack waits for the submission to be acknowledged, so the value is only checked against the price when
+// it was fetched recently. Otherwise it is checked by SaveAlertModal
+func ValidateAlertModal(view slack.View) map[string]string {
+	form := readAlertForm(view)
+	threshold, errors := parseAlertForm(form)
+	if len(errors) > 0 {
+		return errors
+	}
+	if price, found := cryptoPrice(form.Crypto, 0); found {
+		if message := checkThresholdSide(form, threshold, price); message != "" {
+			errors[alertThresholdBlock] = message
+		}
+	}
+	return errors
+}
+
+// SaveAlertModal Saves the submitted alert, already validated, and confirms it to the user where the modal was opened,
+// with the dates in loc. An alert on the wrong side of the price isn't saved, the user is told why instead
+func SaveAlertModal(api *slack.Client, view slack.View, userID string, userName string, loc *time.Location) error {
+	form := readAlertForm(view)
+	threshold, errors := parseAlertForm(form)
+	if len(errors) > 0 {
+		return fmt.Errorf("the alert is not valid anymore: %v", errors)
+	}
+	rule, _ := utils.ParseRule(form.Rule)
+	confirmTo := form.Channel
+	if confirmTo == "" {
+		// Opened from the Home tab, posting to a user ID sends the message to the user's DM with the bot
+		confirmTo = userID
+	}
+	if price, found := cryptoPrice(form.Crypto, savePriceWait); found {
+		if message := checkThresholdSide(form, threshold, price); message != "" {
+			reply := response.New(message+", the alert wasn't saved", "Try again!", response.Highlight, nil, "")
+			reply.AddButton(response.Button{Text: "Set alert", ActionID: SetAlertAction, Value: form.Crypto, Primary: true})
+			_, err := api.PostEphemeral(confirmTo, userID, render.MsgOptions(reply)...)
+			return err
+		}
+	}
+
+	id := form.ID
+	if id == "" {
+		id = time.Now().UTC().Format(time.RFC3339Nano)
+	}
+	expires := ""
+	if form.Expiry == keepExpiry {
+		expires = form.Expires
+	}
+	for _, expiry := range alertExpiries {
+		if form.Expiry == expiry.Value && expiry.Duration > 0 {
+			expires = time.Now().Add(expiry.Duration).UTC().Format(time.RFC3339)
+		}
+	}
+	channel := ""
+	if form.Delivery == deliverToDM {
+		// Posting to a user ID sends the alert to the user's DM with the bot
+		channel = userID
+	}
+
+	saved := utils.NewRule(userName, userID, id, form.Crypto, threshold, rule, expires, channel)
+	err := utils.SaveRule(saved)
+	if err != nil {
+		return err
+	}
+	// Publishing the Home tab is slow, it mustn't delay the confirmation
+	go RefreshHome(api, userName)
+	if form.Channel == "" {
+		return nil
+	}
+	reply := response.New(describeAlert(form.Crypto, rule, threshold, expires, loc), "Good work!", response.Highlight, nil, "")
+	// The ID changes when the edited alert fired or expired meanwhile
+	reply.AddButton(response.Button{Text: "Edit", ActionID: EditAlertAction, Value: saved.ID()})
+	_, err = api.PostEphemeral(form.Channel, userID, render.MsgOptions(reply)...)
+	return err
+}
+
+// describeAlert Tells when the alert fires, and until when
+func describeAlert(crypto string, rule utils.Rules, threshold float64, expires string, loc *time.Location) string {
+	direction := "goes over"
+	if rule.String() == "lowLimit" {
+		direction = "goes under"
+	}
+	text := fmt.Sprintf("I'll let you know when %s %s %s USD", crypto, direction, strconv.FormatFloat(threshold, 'f', -1, 64))
+	if expires != "" {
+		text += ", until " + utils.FormatRuleDate(expires, loc)
+	}
+	return text
+}
+
+// parseAlertForm Reads the threshold of the form, checking every value but the side of the price. Errors are keyed by
+// the block to show them in
+func parseAlertForm(form *alertForm) (float64, map[string]string) {
+	errors := make(map[string]string)
+	if _, found := utils.GetAbbreviatedCryptoName(form.Crypto); !found {
+		errors[alertCryptoBlock] = "Pick one of the cryptos I know"
+	}
+	if _, found := utils.ParseRule(form.Rule); !found {
+		errors[alertRuleBlock] = "Pick when I should tell you"
+	}
+	threshold, err := strconv.ParseFloat(strings.TrimSpace(form.Threshold), 64)
+	if err != nil || threshold <= 0 {
+		errors[alertThresholdBlock] = "That´s not a valid value! It must be a possitive number"
+	}
+	if form.Expiry == keepExpiry && form.Expires == "" {
+		errors[alertExpiryBlock] = "Pick when the alert expires"
+	}
+	return threshold, errors
+}
+
+// checkThresholdSide Tells what is wrong when the alert is on the wrong side of the price, it would fire right away
+func checkThresholdSide(form *alertForm, threshold float64, price float64) string {
+	if form.Rule == "highLimit" && threshold <= price {
+		return fmt.Sprintf("%s is already at %s USD, the value must be higher", form.Crypto, strconv.FormatFloat(price, 'f', -1, 64))
+	}
+	if form.Rule == "lowLimit" && threshold >= price {
+		return fmt.Sprintf("%s is already at %s USD, the value must be lower", form.Crypto, strconv.FormatFloat(price, 'f', -1, 64))
+	}
+	return ""
+}
+
+// cryptoPrice The price of the crypto, from the last minute or waiting at most wait for it to be fetched. It isn't
+// found when the price provider takes longer, the price is still saved for the next time
+func cryptoPrice(crypto string, wait time.Duration) (float64, bool) {
+	modalPricesMutex.Lock()
+	cached, found := modalPrices[crypto]
+	if !found || (isReady(cached) && time.Since(cached.at) > modalPriceAge) {
+		cached = &modalPrice{ready: make(chan struct{})}
+		modalPrices[crypto] = cached
+		go fetchModalPrice(crypto, cached)
+	}
+	modalPricesMutex.Unlock()
+
+	if !isReady(cached) {
+		select {
+		case <-cached.ready:
+		case <-time.After(wait):
+			return 0, false
+		}
+	}
+	return cached.value, cached.value > 0
+}
+
+// fetchModalPrice Gets the price of the crypto for the modal, which is waiting on ready
+func fetchModalPrice(crypto string, price *modalPrice) {
+	text, err := utils.GetCryptoValue(crypto, "USD")
+	if err == nil {
+		value, err := strconv.ParseFloat(text, 64)
+		if err == nil {
+			price.value = value
+		}
+	}
+	price.at = time.Now()
+	close(price.ready)
+}
+
+func isReady(price *modalPrice) bool {
+	select {
+	case <-price.ready:
+		return true
+	default:
+		return false
+	}
+}
+
+// readAlertForm Reads the values of the modal, and the alert ID and channel kept in its metadata
+func readAlertForm(view slack.View) *alertForm {
+	form := &alertForm{}
+	metadata := strings.Split(view.PrivateMetadata, "|")
+	if len(metadata) == 3 {
+		form.ID, form.Channel, form.Expires = metadata[0], metadata[1], metadata[2]
+	}
+	if view.State == nil {
+		return form
+	}
+	values := view.State.Values
+	form.Crypto = values[alertCryptoBlock][AlertCryptoAction].SelectedOption.Value
+	form.Rule = values[alertRuleBlock][alertRuleBlock].SelectedOption.Value
+	form.Threshold = values[alertThresholdBlock][alertThresholdBlock].Value
+	form.Expiry = values[alertExpiryBlock][alertExpiryBlock].SelectedOption.Value
+	form.Delivery = values[alertDeliveryBlock][alertDeliveryBlock].SelectedOption.Value
+	return form
+}
+
+// alertModal Builds the modal showing the form
+func alertModal(form *alertForm) slack.ModalViewRequest {
+	var cryptoOptions []*slack.OptionBlockObject
+	for _, crypto := range utils.SupportedCryptos() {
+		fullCryptoName, _ := utils.GetFullCryptoName(crypto)
+		cryptoOptions = append(cryptoOptions, option(crypto, fmt.Sprintf("%s - %s", crypto, fullCryptoName)))
+	}
+	cryptoSelect := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, plainText("Pick a crypto"), AlertCryptoAction, cryptoOptions...)
+	cryptoSelect.InitialOption = findOption(cryptoOptions, form.Crypto)
+	cryptoBlock := slack.NewInputBlock(alertCryptoBlock, plainText("Crypto"), nil, cryptoSelect)
+	cryptoBlock.DispatchAction = true
+
+	ruleOptions := []*slack.OptionBlockObject{option("highLimit", "It goes over the value"), option("lowLimit", "It goes under the value")}
+	ruleSelect := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, plainText("Pick when"), alertRuleBlock, ruleOptions...)
+	ruleSelect.InitialOption = findOption(ruleOptions, form.Rule)
+
+	thresholdInput := slack.NewPlainTextInputBlockElement(plainText("Value in USD"), alertThresholdBlock)
+	thresholdInput.InitialValue = form.Threshold
+	var thresholdHint *slack.TextBlockObject
+	if form.Crypto != "" {
+		if price, found := cryptoPrice(form.Crypto, hintPriceWait); found {
+			thresholdHint = plainText(fmt.Sprintf("%s is at %s USD now", form.Crypto, strconv.FormatFloat(price, 'f', -1, 64)))
+		}
+	}
+
+	var expiryOptions []*slack.OptionBlockObject
+	if form.Expires != "" {
+		loc := form.Location
+		if loc == nil {
+			loc = utils.DefaultLocation()
+		}
+		expiryOptions = append(expiryOptions, option(keepExpiry, "Keep, "+utils.FormatRuleDate(form.Expires, loc)))
+	}
+	for _, expiry := range alertExpiries {
+		expiryOptions = append(expiryOptions, option(expiry.Value, expiry.Text))
+	}
+	expirySelect := slack.NewOptionsSelectBlockElement(slack.OptTypeStatic, plainText("Pick when"), alertExpiryBlock, expiryOptions...)
+	expirySelect.InitialOption = findOption(expiryOptions, form.Expiry)
+
+	deliveryOptions := []*slack.OptionBlockObject{option(deliverToDM, "In a direct message"), option(deliverToChannel, "In the alerts channel")}
+	deliveryRadio := slack.NewRadioButtonsBlockElement(alertDeliveryBlock, deliveryOptions...)
+	deliveryRadio.InitialOption = findOption(deliveryOptions, form.Delivery)
+
+	title := "New alert"
+	if form.ID != "" {
+		title = "Edit alert"
+	}
+	return slack.ModalViewRequest{
+		Type:            slack.VTModal,
+		CallbackID:      AlertModalCallback,
+		PrivateMetadata: strings.Join([]string{form.ID, form.Channel, form.Expires}, "|"),
+		Title:           plainText(title),
+		Submit:          plainText("Save"),
+		Close:           plainText("Cancel"),
+		Blocks: slack.Blocks{BlockSet: []slack.Block{
+			cryptoBlock,
+			slack.NewInputBlock(alertRuleBlock, plainText("Tell me when"), nil, ruleSelect),
+			slack.NewInputBlock(alertThresholdBlock, plainText("Value"), thresholdHint, thresholdInput),
+			slack.NewInputBlock(alertExpiryBlock, plainText("Expires"), nil, expirySelect),
+			slack.NewInputBlock(alertDeliveryBlock, plainText("Tell me"), nil, deliveryRadio),
+		}},
+	}
+}
+
+func plainText(text string) *slack.TextBlockObject {
+	return slack.NewTextBlockObject(slack.PlainTextType, text, false, false)
+}
+
+func option(value string, text string) *slack.OptionBlockObject {
+	return slack.NewOptionBlockObject(value, plainText(text), nil)
+}
+
+// findOption The option with the value, nil when there is none so the select starts empty
+func findOption(options []*slack.OptionBlockObject, value string) *slack.OptionBlockObject {
+	for _, candidate := range options {
+		if candidate.Value == value {
+			return candidate
+		}
+	}
+	return nil
+}
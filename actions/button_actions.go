@@ -0,0 +1,29 @@
+package actions
+
+import (
+	"crypto-bot/response"
+	"strings"
+)
+
+// CommandAction Buttons whose value is a command to run again, their reply replaces the message with the button
+const CommandAction = "command"
+
+// SetAlertAction Button that opens the alert modal, its value is the crypto picked in it
+const SetAlertAction = "set_alert"
+
+// chartRanges Ranges of the chart buttons
+var chartRanges = []string{"24h", "30d", "1y"}
+
+// addMarketButtons Adds the 24h, 30d and 1y charts, set alert and refresh buttons to a price or chart reply. options
+// are the +flags and indicators kept when the range changes, refresh is the command that made the reply
+func addMarketButtons(reply *response.Response, cryptos string, options []string, refresh string) *response.Response {
+	for _, chartRange := range chartRanges {
+		command := strings.Join(append([]string{GetChart, cryptos, chartRange}, options...), " ")
+		reply.AddButton(response.Button{Text: chartRange + " chart", ActionID: CommandAction + "_" + chartRange, Value: command})
+	}
+	if !strings.Contains(cryptos, ",") {
+		reply.AddButton(response.Button{Text: "Set alert", ActionID: SetAlertAction, Value: cryptos})
+	}
+	reply.AddButton(response.Button{Text: "Refresh", ActionID: CommandAction + "_refresh", Value: refresh})
+	return reply
+}
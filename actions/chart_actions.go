@@ -2,16 +2,25 @@ package actions
 
 import (
 	"bytes"
+	"crypto-bot/chartjs"
+	"crypto-bot/commands"
+	"crypto-bot/downsample"
+	"crypto-bot/indicators"
+	"crypto-bot/response"
 	"crypto-bot/utils"
 	"encoding/json"
 	"fmt"
-	"github.com/slack-go/slack"
 	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/slack-go/slack"
 )
 
 type ChartResponse struct {
@@ -21,14 +30,14 @@ type ChartResponse struct {
 }
 
 type Chart struct {
-	Width             int64   `json:"width"`
-	Height            int64   `json:"height"`
-	DevicePixelRation float64 `json:"devicePixelRatio"`
-	Format            string  `json:"format"`
-	BackgroundColor   string  `json:"backgroundColor"`
-	Key               string  `json:"key"`
-	Version           string  `json:"version,omitempty"`
-	Config            string  `json:"chart"`
+	Width             int64           `json:"width"`
+	Height            int64           `json:"height"`
+	DevicePixelRation float64         `json:"devicePixelRatio"`
+	Format            string          `json:"format"`
+	BackgroundColor   string          `json:"backgroundColor"`
+	Key               string          `json:"key"`
+	Version           string          `json:"version,omitempty"`
+	Config            *chartjs.Config `json:"chart"`
 
 	Scheme  string        `json:"-"`
 	Host    string        `json:"-"`
@@ -41,93 +50,340 @@ type ShortURLResponse struct {
 	URL     string `json:"url"`
 }
 
-func HandleChart(splitedText []string, fields []slack.AttachmentField) slack.Attachment {
-	var text, pretext, color, image string
-	args := len(splitedText)
-	if args < 4 { // args = 3
-		return utils.GetAttachment("Please try again", "Command error", "#ff0000", fields, "")
-	} else {
-		if args < 5 { // arg = 4
-			crypto := splitedText[2]
-			d2 := time.Now()
-			r1 := splitedText[3]
-			var d1 time.Time
-			var chart string
-			var err error
-			switch r1 {
-			case "24h":
-				d1 = time.Now().AddDate(0, 0, -1)
-				chart, err = getChartUrl(crypto, d1, d2)
-			case "30d":
-				d1 = time.Now().AddDate(0, -1, 0)
-				chart, err = getChartUrl(crypto, d1, d2)
-			case "1y":
-				d1 = time.Now().AddDate(-1, 0, 0)
-				chart, err = getChartUrl(crypto, d1, d2)
-			default:
-				err = fmt.Errorf("that's not a true command")
-			}
-			if err == nil {
-				pretext = "As you wanted"
-				text = "Here is the historical market price for that data range"
-				color = "#0000ff"
-				image = chart
+// ChartOptions Extra panels and settings requested with +flags after the chart command
+type ChartOptions struct {
+	Volume     bool
+	MarketCap  bool
+	Sampling   downsample.Strategy
+	Indicators []Indicator
+	Alerts     AlertsMode
+}
+
+// AlertsMode Whose active rules are drawn on the chart
+type AlertsMode int
+
+const (
+	noAlerts AlertsMode = iota
+	// userAlerts Only the rules of who asked for the chart
+	userAlerts
+	// channelAlerts The rules of the members of the channel
+	channelAlerts
+)
+
+// Indicator An overlay requested for the chart, like sma20, ema12 or bb
+type Indicator struct {
+	Kind   string
+	Period int
+}
+
+// Colors of the indicator overlays, in the order they are requested
+var indicatorColors = []string{"#ff8000", "#9b59b6", "#16a085", "#c0392b", "#7f8c8d"}
+
+// Bollinger Bands are drawn this many standard deviations away from the middle band
+const bollingerDeviations = 2.0
+
+func HandleChart(api *slack.Client, args commands.Values, flags []string, userName string, channel string, loc *time.Location, fields []response.Field) *response.Response {
+	var text, pretext, image string
+	var kind response.Kind
+	rangeArgs, requested, err := parseChartRange(args.List("range"))
+	if err != nil {
+		return response.New(err.Error(), "Command error", response.Error, fields, "")
+	}
+	options, err := parseChartFlags(flags)
+	if err != nil {
+		return response.New(err.Error(), "Command error", response.Error, fields, "")
+	}
+	options.Indicators = requested
+	// The command registry already checked the cryptos and that there is a range
+	cryptoArg := args.Arg("cryptos")
+	cryptos := strings.Split(cryptoArg, ",")
+	var chart string
+	timeRange, err := utils.ParseTimeRange(rangeArgs, time.Now(), loc)
+	d1, d2 := timeRange.From, timeRange.To
+	if err == nil {
+		if len(cryptos) > 1 {
+			if options.Volume || options.MarketCap || len(options.Indicators) > 0 || options.Alerts != noAlerts {
+				err = fmt.Errorf("panels, indicators and alerts are only available when charting a single crypto")
 			} else {
-				return utils.GetAttachment(err.Error(), "I'm Sorry", "#ff0000", fields, "")
+				chart, err = getComparisonChartUrl(cryptos, d1, d2, loc, options)
 			}
-		} else { // arg = 5
-			crypto := splitedText[2]
-			d1, _ := time.Parse(utils.Layout, splitedText[3])
-			d2, _ := time.Parse(utils.Layout, splitedText[4])
-			chart, err := getChartUrl(crypto, d1, d2)
-			if err == nil {
-				pretext = "As you wanted"
-				text = "Here is the historical market price for that data range"
-				color = "#0000ff"
-				image = chart
-			} else {
-				return utils.GetAttachment(err.Error(), "I'm Sorry", "#ff0000", fields, "")
+		} else {
+			chart, err = getChartUrl(api, cryptos[0], d1, d2, userName, channel, loc, options)
+		}
+	}
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	pretext = "As you wanted"
+	text = "Here is the historical market price for that data range"
+	if len(cryptos) > 1 {
+		text = "Here is the % change of each crypto since the start of that data range"
+	}
+	kind = response.Info
+	image = chart
+
+	reply := response.New(text, pretext, kind, fields, image)
+	kept := chartOptionArgs(args.List("range"), flags)
+	refresh := strings.Join(append(append([]string{GetChart, cryptoArg}, rangeArgs...), kept...), " ")
+	return addMarketButtons(reply, cryptoArg, kept, refresh)
+}
+
+// chartOptionArgs The indicators and +flags of a chart command, kept by the chart buttons
+func chartOptionArgs(rangeArgs []string, flags []string) []string {
+	var options []string
+	for _, arg := range rangeArgs {
+		if _, isIndicator := parseIndicator(arg); isIndicator {
+			options = append(options, arg)
+		}
+	}
+	for _, flag := range flags {
+		options = append(options, "+"+flag)
+	}
+	return options
+}
+
+// parseChartRange Takes the indicators out of the range, they can be written anywhere after the cryptos
+func parseChartRange(args []string) ([]string, []Indicator, error) {
+	var rangeArgs []string
+	var indicators []Indicator
+	for _, arg := range args {
+		indicator, isIndicator := parseIndicator(arg)
+		if !isIndicator {
+			rangeArgs = append(rangeArgs, arg)
+			continue
+		}
+		if indicator.Period <= 1 {
+			return nil, nil, fmt.Errorf("%s needs a period greater than 1, like %s20", arg, indicator.Kind)
+		}
+		indicators = append(indicators, indicator)
+	}
+	return rangeArgs, indicators, nil
+}
+
+// parseChartFlags Reads the +flags of the chart, the command registry already checked they exist
+func parseChartFlags(flags []string) (ChartOptions, error) {
+	options := ChartOptions{Sampling: downsample.Default}
+	for _, flag := range flags {
+		switch flag {
+		case "volume":
+			options.Volume = true
+		case "mcap":
+			options.MarketCap = true
+		case "alerts":
+			options.Alerts = userAlerts
+		case "allalerts":
+			options.Alerts = channelAlerts
+		default:
+			strategy, found := downsample.ParseStrategy(flag)
+			if !found {
+				return options, fmt.Errorf("I don't know the +%s option, try +volume, +mcap, +alerts, +allalerts, +lttb, +minmax or +nth", flag)
 			}
+			options.Sampling = strategy
 		}
 	}
+	return options, nil
+}
 
-	return utils.GetAttachment(text, pretext, color, fields, image)
+// parseIndicator Reads indicators like sma20 or ema12. Bollinger Bands (bb) default to a 20 period
+func parseIndicator(arg string) (Indicator, bool) {
+	for _, kind := range []string{"sma", "ema", "bb"} {
+		if !strings.HasPrefix(arg, kind) {
+			continue
+		}
+		period := strings.TrimPrefix(arg, kind)
+		if period == "" && kind == "bb" {
+			return Indicator{Kind: kind, Period: 20}, true
+		}
+		value, err := strconv.Atoi(period)
+		if err != nil {
+			return Indicator{}, false
+		}
+		return Indicator{Kind: kind, Period: value}, true
+	}
+	return Indicator{}, false
 }
 
-func getChartUrl(crypto string, date1 time.Time, date2 time.Time) (string, error) {
+// buildOverlays Computes the indicators over the full resolution prices
+func buildOverlays(prices [][]float64, requested []Indicator) []utils.Overlay {
+	values := make([]float64, len(prices))
+	for i, price := range prices {
+		values[i] = price[1]
+	}
+
+	var overlays []utils.Overlay
+	for i, indicator := range requested {
+		color := indicatorColors[i%len(indicatorColors)]
+		switch indicator.Kind {
+		case "sma":
+			overlays = append(overlays, utils.Overlay{Label: fmt.Sprintf("SMA %d", indicator.Period), Values: indicators.SMA(values, indicator.Period), Color: color})
+		case "ema":
+			overlays = append(overlays, utils.Overlay{Label: fmt.Sprintf("EMA %d", indicator.Period), Values: indicators.EMA(values, indicator.Period), Color: color})
+		case "bb":
+			middle, upper, lower := indicators.BollingerBands(values, indicator.Period, bollingerDeviations)
+			label := fmt.Sprintf("BB %d", indicator.Period)
+			overlays = append(overlays,
+				utils.Overlay{Label: label + " middle", Values: middle, Color: color, Dash: []float64{4, 4}},
+				utils.Overlay{Label: label + " upper", Values: upper, Color: color},
+				utils.Overlay{Label: label + " lower", Values: lower, Color: color, Fill: "-1"},
+			)
+		}
+	}
+	return overlays
+}
+
+// addAlertLines Draws a horizontal line for each active rule on the crypto, only the user ones unless mode is
+// channelAlerts. Then the rules of the members of the channel are drawn, only with their level and user. When the
+// members can't be read, like in channels the bot hasn't joined, only the user rules are drawn
+func addAlertLines(api *slack.Client, config *chartjs.Config, fullCryptoName string, userName string, channel string, mode AlertsMode) error {
+	rules, err := utils.LoadActiveRules(utils.RulesFileName())
+	if err != nil {
+		return err
+	}
+	abbreviatedCryptoName, _ := utils.GetAbbreviatedCryptoName(fullCryptoName)
+	var members map[string]bool
+	if mode == channelAlerts {
+		members, err = channelMembers(api, channel)
+		if err != nil {
+			log.Println("Error getting the members of", channel, "to draw their alerts", err)
+			mode = userAlerts
+		}
+	}
+	for i, rule := range rules {
+		if rule.Crypto() != abbreviatedCryptoName || (mode == userAlerts && rule.User() != userName) {
+			continue
+		}
+		if mode == channelAlerts && !members[ruleUserID(rule)] {
+			continue
+		}
+		color := "#3aa030"
+		if rule.Rule().String() == "lowLimit" {
+			color = "#ff0000"
+		}
+		label := fmt.Sprintf("%s %s", rule.Rule(), strconv.FormatFloat(rule.Price(), 'f', -1, 64))
+		if mode == channelAlerts {
+			label += " (" + rule.User() + ")"
+		}
+		config.AddAnnotation(fmt.Sprintf("alert%d", i), chartjs.NewHorizontalLine("price", rule.Price(), color, label))
+		config.Options.Scales["price"].Include(rule.Price())
+	}
+	return nil
+}
+
+// channelMembers The IDs of the members of the channel
+func channelMembers(api *slack.Client, channel string) (map[string]bool, error) {
+	members := make(map[string]bool)
+	params := &slack.GetUsersInConversationParameters{ChannelID: channel}
+	for {
+		userIDs, cursor, err := api.GetUsersInConversation(params)
+		if err != nil {
+			return nil, err
+		}
+		for _, userID := range userIDs {
+			members[userID] = true
+		}
+		if cursor == "" {
+			return members, nil
+		}
+		params.Cursor = cursor
+	}
+}
+
+func getChartUrl(api *slack.Client, crypto string, date1 time.Time, date2 time.Time, userName string, channel string, loc *time.Location, options ChartOptions) (string, error) {
 
 	fullCryptoName, found := utils.GetFullCryptoName(crypto)
 	if !found {
 		return "", fmt.Errorf("I don't support that Crypto ID or it doesn't exist (yet)")
 	}
 
-	tp1u := date1.Unix() + 10800
-	fmt.Println(strconv.Itoa(int(tp1u)))
-	tp2u := date2.Unix() + 10800
-	fmt.Println(strconv.Itoa(int(tp2u)))
-	if tp1u >= tp2u {
-		return "", fmt.Errorf("Data range is not valid")
+	JsonResponse, err := getMarketChart(fullCryptoName, "usd", date1, date2)
+	if err != nil {
+		return "", err
 	}
 
-	response, err := http.Get("https://api.coingecko.com/api/v3/coins/" + fullCryptoName + "/market_chart/range?vs_currency=usd&from=" + strconv.Itoa(int(tp1u)) + "&to=" + strconv.Itoa(int(tp2u)))
+	prices := JsonResponse.Prices
+	if len(prices) == 0 {
+		return "", fmt.Errorf("there is no data for %s in that data range", fullCryptoName)
+	}
 
-	var JsonResponse ChartResponse
+	data := utils.PriceChartData{
+		Crypto:   fullCryptoName,
+		Location: loc,
+		Prices:   prices,
+		Overlays: buildOverlays(prices, options.Indicators),
+	}
+	if options.Volume {
+		data.Volumes = JsonResponse.TotalVolumes
+	}
+	if options.MarketCap {
+		data.MarketCaps = JsonResponse.MarketCaps
+	}
+	config := utils.BuildPriceChart(data, options.Sampling)
+	if options.Alerts != noAlerts {
+		err = addAlertLines(api, config, fullCryptoName, userName, channel, options.Alerts)
+		if err != nil {
+			return "", fmt.Errorf("I couldn't read the alerts, please try again")
+		}
+	}
 
-	body, _ := ioutil.ReadAll(response.Body)
-	err = json.Unmarshal(body, &JsonResponse)
+	return renderChart(config)
+}
 
+// getComparisonChartUrl Plots several cryptos on the same chart, each one as its % change since date1
+func getComparisonChartUrl(cryptos []string, date1 time.Time, date2 time.Time, loc *time.Location, options ChartOptions) (string, error) {
+
+	fullCryptoNames := make([]string, len(cryptos))
+	for i, crypto := range cryptos {
+		fullCryptoName, found := utils.GetFullCryptoName(crypto)
+		if !found {
+			return "", fmt.Errorf("I don't support %s or it doesn't exist (yet)", crypto)
+		}
+		fullCryptoNames[i] = fullCryptoName
+	}
+
+	series, err := getPriceSeries(fullCryptoNames, date1, date2)
 	if err != nil {
-		return "", fmt.Errorf("unexpected error, please try again (Um)")
+		return "", err
 	}
 
-	prices := JsonResponse.Prices
+	timestamps, values := utils.AlignSeries(series)
+	for i := range values {
+		values[i] = utils.NormalizeToPercentChange(values[i])
+	}
 
-	pricesQuantity := len(prices)
-	fmt.Println("Cantidad de puntos: ", pricesQuantity)
+	return renderChart(utils.BuildComparisonChart(timestamps, values, fullCryptoNames, "% change", loc, options.Sampling))
+}
+
+// getPriceSeries Fetches concurrently the USD prices of every crypto between both dates
+func getPriceSeries(fullCryptoNames []string, date1 time.Time, date2 time.Time) ([][][]float64, error) {
+	responses := make([]*ChartResponse, len(fullCryptoNames))
+	errs := make([]error, len(fullCryptoNames))
+	var wg sync.WaitGroup
+	for i, fullCryptoName := range fullCryptoNames {
+		wg.Add(1)
+		go func(i int, fullCryptoName string) {
+			defer wg.Done()
+			responses[i], errs[i] = getMarketChart(fullCryptoName, "usd", date1, date2)
+		}(i, fullCryptoName)
+	}
+	wg.Wait()
+
+	series := make([][][]float64, len(responses))
+	for i, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+		if len(responses[i].Prices) == 0 {
+			return nil, fmt.Errorf("there is no data for %s in that data range", fullCryptoNames[i])
+		}
+		series[i] = responses[i].Prices
+	}
+	return series, nil
+}
 
-	dataJson, _ := utils.BuildJSONDataFromData(prices, fullCryptoName)
+// renderChart Gets the QuickChart image URL of the chart
+func renderChart(config *chartjs.Config) (string, error) {
 	quickChart := NewChart()
-	quickChart.Config = fmt.Sprintf("{type:'line',options:{elements:{point:{radius:0}}},%s}", dataJson)
+	quickChart.Config = config
 
 	quickChartURL, err := quickChart.getShortUrl()
 	if err != nil {
@@ -137,6 +393,39 @@ func getChartUrl(crypto string, date1 time.Time, date2 time.Time) (string, error
 	return quickChartURL, nil
 }
 
+// getMarketChart Fetches the market chart of the crypto between both dates from CoinGecko, priced in currency
+func getMarketChart(fullCryptoName string, currency string, date1 time.Time, date2 time.Time) (*ChartResponse, error) {
+
+	tp1u := date1.Unix()
+	tp2u := date2.Unix()
+	if tp1u >= tp2u {
+		return nil, fmt.Errorf("Data range is not valid")
+	}
+
+	response, err := http.Get("https://api.coingecko.com/api/v3/coins/" + fullCryptoName + "/market_chart/range?vs_currency=" + url.QueryEscape(currency) + "&from=" + strconv.Itoa(int(tp1u)) + "&to=" + strconv.Itoa(int(tp2u)))
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error, please try again (Get)")
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the price provider answered %s, please try again", response.Status)
+	}
+
+	var JsonResponse ChartResponse
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error, please try again (Read)")
+	}
+	err = json.Unmarshal(body, &JsonResponse)
+
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error, please try again (Um)")
+	}
+
+	return &JsonResponse, nil
+}
+
 func NewChart() *Chart {
 	return &Chart{
 		Width:             1000,
@@ -144,6 +433,7 @@ func NewChart() *Chart {
 		DevicePixelRation: 1.0,
 		Format:            "png",
 		BackgroundColor:   "#ffffff",
+		Version:           chartjs.Version,
 
 		Scheme:  "https",
 		Host:    "quickchart.io",
@@ -153,7 +443,7 @@ func NewChart() *Chart {
 }
 
 func (quickChart *Chart) validateConfig() bool {
-	return len(quickChart.Config) != 0
+	return quickChart.Config != nil && len(quickChart.Config.Data.Datasets) != 0
 }
 
 func (quickChart *Chart) getShortUrl() (string, error) {
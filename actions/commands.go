@@ -0,0 +1,335 @@
+package actions
+
+import (
+	"crypto-bot/commands"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+)
+
+// cryptoArg, rangeArg, quantityArg, priceArg, intervalArg Arguments shared by several commands
+var (
+	intervalArg = commands.Argument{Name: "interval", Type: commands.Interval}
+	cryptoArg   = commands.Argument{Name: "crypto", Type: commands.Crypto}
+	rangeArg    = commands.Argument{Name: "range", Type: commands.Text, Variadic: true}
+	quantityArg = commands.Argument{Name: "quantity", Type: commands.Number}
+	priceArg    = commands.Argument{Name: "price", Type: commands.Number}
+)
+
+// Categories of the commands, in the order the help shows them
+const (
+	generalCategory    = "General"
+	marketCategory     = "Prices and charts"
+	alertsCategory     = "Alerts and watchlists"
+	portfolioCategory  = "Portfolio"
+	simulationCategory = "Simulations and paper trading"
+)
+
+var helpCategories = []string{generalCategory, marketCategory, alertsCategory, portfolioCategory, simulationCategory}
+
+func init() {
+	commands.Register(&commands.Command{
+		Name:     Hello,
+		Category: generalCategory,
+		Aliases:  []string{"hi"},
+		Summary:  "Greet me!",
+		Examples: []string{"hello"},
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleHello(ctx.User, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     Help,
+		Category: generalCategory,
+		Summary:  "Tells you what I can do",
+		Args:     []commands.Argument{{Name: "command", Type: commands.Text, Optional: true, Variadic: true}},
+		Examples: []string{"help", "help chart", "help paper buy"},
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleHelp(ctx.Values, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     CryptoList,
+		Category: generalCategory,
+		Aliases:  []string{"cryptos", "coins"},
+		Summary:  "Lists the cryptos I know, to show data or set rules",
+		Examples: []string{"cryptoList"},
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleCryptoList(ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     Price,
+		Category: marketCategory,
+		Aliases:  []string{"p"},
+		Summary:  "Gets the current price of the crypto",
+		Args:     []commands.Argument{cryptoArg},
+		Examples: []string{"price btc"},
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandlePrice(ctx.Values, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     Live,
+		Category: marketCategory,
+		Summary:  "Posts the price and keeps it updated with the change since the start, for up to an hour",
+		Args:     []commands.Argument{cryptoArg, {Name: "duration", Type: commands.Interval, Optional: true}},
+		Options:  []commands.Option{{Keywords: []string{"every"}, Arg: intervalArg}},
+		Examples: []string{"live btc 15m", "live eth 30m every 10s"},
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleLive(ctx.API, ctx.Channel, ctx.Thread, ctx.User.ID, ctx.Values, ctx.Location, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:        SetHigh,
+		Category:    alertsCategory,
+		Summary:     "Tells you when the crypto goes over the value",
+		Args:        []commands.Argument{cryptoArg, {Name: "value", Type: commands.Number}},
+		Examples:    []string{"setHigh btc 70000"},
+		Permissions: "Anyone. The alert is yours, and I tell you about it by direct message",
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleSetLimit(ctx.API, ctx.Values, ctx.User.ID, ctx.UserName, "highLimit", ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:        SetLow,
+		Category:    alertsCategory,
+		Summary:     "Tells you when the crypto goes under the value",
+		Args:        []commands.Argument{cryptoArg, {Name: "value", Type: commands.Number}},
+		Examples:    []string{"setLow eth 1500"},
+		Permissions: "Anyone. The alert is yours, and I tell you about it by direct message",
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleSetLimit(ctx.API, ctx.Values, ctx.User.ID, ctx.UserName, "lowLimit", ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:        Alert,
+		Category:    alertsCategory,
+		Summary:     "Opens a form to set an alert, with when it expires and where I tell you",
+		Args:        []commands.Argument{{Name: "crypto", Type: commands.Crypto, Optional: true}},
+		Examples:    []string{"alert btc"},
+		Permissions: "Anyone. The alert is yours",
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleAlert(ctx.Values, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     GetChart,
+		Category: marketCategory,
+		Summary:  "Charts the price within a range (24h, 30d, 1y, ytd, max, since a date or two dates) with optional sma20, ema50 or bb overlays. Several comma separated cryptos are compared by % change",
+		Args:     []commands.Argument{{Name: "cryptos", Type: commands.Cryptos}, rangeArg},
+		Flags: []commands.Flag{
+			{Name: "volume", Description: "adds the volume panel"},
+			{Name: "mcap", Description: "adds the market cap panel"},
+			{Name: "alerts", Description: "draws your active alerts"},
+			{Name: "allalerts", Description: "draws the active alerts of the channel members"},
+			{Name: "lttb", Description: "reduces the points keeping the shape (default)"},
+			{Name: "minmax", Description: "reduces the points keeping the highs and lows"},
+			{Name: "nth", Description: "reduces the points keeping one every n"},
+		},
+		Examples: []string{"chart btc 30d", "chart btc,eth,sol 1y", "chart eth since 01-01-2022 sma20 +volume", "chart btc 01-03-2022 05-03-2022"},
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleChart(ctx.API, ctx.Values, ctx.Flags, ctx.UserName, ctx.Channel, ctx.Location, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     Export,
+		Category: marketCategory,
+		Summary:  "Uploads a file with the price, market cap and volume within a range, optionally in another currency or resampled",
+		Args: []commands.Argument{
+			cryptoArg,
+			rangeArg,
+			{Name: "format", Type: commands.Choice, Choices: []string{"csv", "json"}},
+		},
+		Options: []commands.Option{
+			{Keywords: []string{"in"}, Arg: commands.Argument{Name: "currency", Type: commands.Text}},
+			{Keywords: []string{"every"}, Arg: intervalArg},
+		},
+		Examples: []string{"export btc 30d csv", "export eth 1y json in eur every 1d"},
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleExport(ctx.API, ctx.UploadChannel, uploadThread(ctx), ctx.Values, ctx.Location, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:        Timezone,
+		Category:    generalCategory,
+		Aliases:     []string{"tz"},
+		Summary:     "Shows, sets or resets the timezone I use for your dates",
+		Args:        []commands.Argument{{Name: "timezone|reset", Type: commands.Text, Optional: true}},
+		Examples:    []string{"timezone America/New_York", "timezone reset"},
+		Permissions: "Anyone, it only changes your own timezone",
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleTimezone(ctx.Values, ctx.UserName, ctx.User.TZ, ctx.Fields)
+		},
+	})
+	for _, side := range []string{Buy, Sell} {
+		side := side
+		commands.Register(&commands.Command{
+			Name:        side,
+			Category:    portfolioCategory,
+			Summary:     "Records a transaction in your portfolio, at the current price if there is none",
+			Args:        []commands.Argument{quantityArg, cryptoArg},
+			Options:     []commands.Option{{Keywords: []string{"at"}, Arg: priceArg}},
+			Examples:    []string{side + " 0.5 btc at 30000", side + " 2 eth"},
+			Permissions: "Anyone, it only changes your own portfolio",
+			Handler: func(ctx *commands.Context) *response.Response {
+				return HandleTrade(ctx.Values, ctx.UserName, side, ctx.Fields)
+			},
+		})
+	}
+	commands.Register(&commands.Command{
+		Name:     Portfolio,
+		Category: portfolioCategory,
+		Aliases:  []string{"pf"},
+		Summary:  "Shows your holdings and P&L, charts their value over time or their allocation",
+		Subcommands: []*commands.Command{
+			{
+				Name:     "chart",
+				Summary:  "Charts the value of your portfolio within the range, since your first transaction by default",
+				Args:     []commands.Argument{{Name: "range", Type: commands.Text, Optional: true, Variadic: true}},
+				Examples: []string{"portfolio chart 90d"},
+			},
+			{
+				Name:     "allocation",
+				Summary:  "Charts the weight of each crypto in your portfolio",
+				Args:     []commands.Argument{{Name: "style", Type: commands.Choice, Choices: []string{"pie", "bar"}, Optional: true}},
+				Examples: []string{"portfolio allocation bar"},
+			},
+		},
+		Examples:    []string{"portfolio", "portfolio chart 90d", "portfolio allocation pie"},
+		Permissions: "Anyone, it only shows your own portfolio",
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandlePortfolio(ctx.Values, ctx.UserName, ctx.Location, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:        Import,
+		Category:    portfolioCategory,
+		Summary:     "Imports your trades from a Coinbase, Binance or Kraken CSV uploaded in the same message",
+		Examples:    []string{"import"},
+		Permissions: "Anyone, it only changes your own portfolio",
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleImport(ctx.API, ctx.Channel, ctx.Timestamp, ctx.MessageThread, ctx.Message, ctx.User.ID, ctx.UserName, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     Gains,
+		Category: portfolioCategory,
+		Summary:  "Uploads a CSV with the realized gain of every lot you sold that year",
+		Args: []commands.Argument{
+			{Name: "year", Type: commands.Integer},
+			{Name: "method", Type: commands.Choice, Choices: []string{"fifo", "lifo", "average"}, Optional: true},
+		},
+		Examples:    []string{"gains 2022", "gains 2022 lifo"},
+		Permissions: "Anyone, it only reads your own portfolio",
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleGains(ctx.API, ctx.UploadChannel, uploadThread(ctx), ctx.Values, ctx.UserName, ctx.Location, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     Watch,
+		Category: alertsCategory,
+		Summary:  "Keeps your watchlist (or the channel's) and posts a daily digest of it",
+		Subcommands: append(watchSubcommands("watch"), &commands.Command{
+			Name:        "channel",
+			Summary:     "The same subcommands, on the watchlist of the channel",
+			Subcommands: watchSubcommands("watch channel"),
+			Examples:    []string{"watch channel add btc", "watch channel digest 09:00"},
+		}),
+		Examples:    []string{"watch add btc eth sol", "watch digest 09:00", "watch channel add btc"},
+		Permissions: "Anyone. Your watchlist is only yours, the channel one can be changed by anyone in the channel",
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleWatch(ctx.Values, ctx.User.ID, ctx.UserName, ctx.Channel, ctx.Location, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     Paper,
+		Category: simulationCategory,
+		Summary:  "Trades with a virtual balance using market, limit and stop orders",
+		Subcommands: []*commands.Command{
+			paperOrderSubcommand(utils.Buy),
+			paperOrderSubcommand(utils.Sell),
+			{Name: "positions", Summary: "Shows your cash and positions", Examples: []string{"paper positions"}},
+			{Name: "orders", Summary: "Lists your open orders and the last closed ones", Examples: []string{"paper orders"}},
+			{
+				Name:     "cancel",
+				Summary:  "Cancels an open order",
+				Args:     []commands.Argument{{Name: "order", Type: commands.ID}},
+				Examples: []string{"paper cancel 3"},
+			},
+			{Name: "leaderboard", Summary: "Ranks the paper accounts of who traded in the channel", Examples: []string{"paper leaderboard"}},
+			{Name: "reset", Summary: "Starts your paper account again", Examples: []string{"paper reset"}},
+		},
+		Examples:    []string{"paper buy 0.5 btc", "paper sell 1 eth limit 4000", "paper orders", "paper leaderboard"},
+		Permissions: "Anyone, on your own paper account. The leaderboard shows everyone who traded in the channel",
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandlePaper(ctx.Values, ctx.UserName, ctx.Channel, ctx.Location, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     DCA,
+		Category: simulationCategory,
+		Summary:  "Simulates buying the same USD amount every period",
+		Args: []commands.Argument{
+			cryptoArg,
+			{Name: "amount", Type: commands.Number},
+			{Name: "frequency", Type: commands.Choice, Choices: []string{"daily", "weekly", "biweekly", "monthly"}},
+			rangeArg,
+		},
+		Flags:    []commands.Flag{{Name: "chart", Description: "plots invested vs value"}},
+		Examples: []string{"dca btc 100 weekly since 01-01-2021", "dca eth 50 monthly 2y +chart"},
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleDCA(ctx.Values, ctx.Location, ctx.Fields)
+		},
+	})
+	commands.Register(&commands.Command{
+		Name:     WhatIf,
+		Category: simulationCategory,
+		Summary:  "Tells what a USD amount bought on a past date would be worth now",
+		Args: []commands.Argument{
+			cryptoArg,
+			{Name: "amount", Type: commands.Number},
+			{Name: "on", Type: commands.Choice, Choices: []string{"on"}},
+			{Name: "date", Type: commands.Date},
+		},
+		Flags:    []commands.Flag{{Name: "chart", Description: "plots invested vs value"}},
+		Examples: []string{"whatif eth 1000 on 01-06-2020"},
+		Handler: func(ctx *commands.Context) *response.Response {
+			return HandleWhatIf(ctx.Values, ctx.Location, ctx.Fields)
+		},
+	})
+}
+
+// watchSubcommands The subcommands of a watchlist, the user's one or the channel's. Their examples start with command
+func watchSubcommands(command string) []*commands.Command {
+	cryptosArg := commands.Argument{Name: "cryptos", Type: commands.Crypto, Variadic: true}
+	return []*commands.Command{
+		{Name: "list", Summary: "Shows the watchlist and its digest", Examples: []string{command + " list"}},
+		{Name: "add", Summary: "Adds the cryptos to the watchlist", Args: []commands.Argument{cryptosArg}, Examples: []string{command + " add btc eth sol"}},
+		{Name: "remove", Summary: "Takes the cryptos out of the watchlist", Args: []commands.Argument{cryptosArg}, Examples: []string{command + " remove sol"}},
+		{
+			Name:     "digest",
+			Summary:  "Posts the digest every day at that time, or stops it",
+			Args:     []commands.Argument{{Name: "time", Type: commands.Text, Usage: "<HH:MM|off>"}},
+			Examples: []string{command + " digest 09:00", command + " digest off"},
+		},
+	}
+}
+
+// paperOrderSubcommand The paper buy or sell subcommand, a market order unless it has a limit or stop price
+func paperOrderSubcommand(side string) *commands.Command {
+	return &commands.Command{
+		Name:     side,
+		Summary:  "Places a market order, or a limit or stop order filled when the price crosses it",
+		Args:     []commands.Argument{quantityArg, cryptoArg},
+		Options:  []commands.Option{{Keywords: []string{utils.LimitOrder, utils.StopOrder}, Arg: priceArg}},
+		Examples: []string{"paper " + side + " 0.5 btc", "paper " + side + " 0.5 btc limit 30000"},
+	}
+}
+
+// uploadThread Thread the files of the command are uploaded to, the one of the reply unless they go to another channel
+func uploadThread(ctx *commands.Context) string {
+	if ctx.UploadChannel != ctx.Channel {
+		return ""
+	}
+	return ctx.Thread
+}
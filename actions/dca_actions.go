@@ -0,0 +1,120 @@
+package actions
+
+import (
+	"crypto-bot/commands"
+	"crypto-bot/downsample"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// HandleDCA Simulates buying the same USD amount of a crypto every period: dca btc 100 weekly since 01-01-2021 [+chart]
+func HandleDCA(args commands.Values, loc *time.Location, fields []response.Field) *response.Response {
+	fullCryptoName, amount, err := parseSimulationArgs(args.Arg("crypto"), args.Arg("amount"))
+	if err != nil {
+		return response.New(err.Error(), "Try again!", response.Highlight, fields, "")
+	}
+	timeRange, err := utils.ParseTimeRange(args.List("range"), time.Now(), loc)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	frequency := args.Arg("frequency")
+	dates, err := utils.BuyDates(frequency, timeRange.From, timeRange.To)
+	if err != nil {
+		return response.New(err.Error(), "Try again!", response.Highlight, fields, "")
+	}
+
+	series, err := getPriceSeries([]string{fullCryptoName}, timeRange.From, timeRange.To)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	simulation, err := utils.Simulate(series[0], amount, dates)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+
+	text := fmt.Sprintf("Buying %.2f USD of %s %s from %s:\n%s", amount, fullCryptoName, frequency, utils.FormatDate(timeRange.From, loc), describeSimulation(simulation))
+	return simulationResponse(text, simulation, args.Flag("chart"), loc, fields)
+}
+
+// HandleWhatIf Simulates a lump-sum buy on a past date, compared with spreading it weekly until now:
+// whatif eth 1000 on 01-06-2020 [+chart]
+func HandleWhatIf(args commands.Values, loc *time.Location, fields []response.Field) *response.Response {
+	fullCryptoName, amount, err := parseSimulationArgs(args.Arg("crypto"), args.Arg("amount"))
+	if err != nil {
+		return response.New(err.Error(), "Try again!", response.Highlight, fields, "")
+	}
+	date, _, err := utils.ParseDate(args.Arg("date"), loc)
+	if err != nil {
+		return response.New(err.Error(), "Try again!", response.Highlight, fields, "")
+	}
+	now := time.Now()
+	if !date.Before(now) {
+		return response.New("The date must be in the past", "Try again!", response.Highlight, fields, "")
+	}
+
+	series, err := getPriceSeries([]string{fullCryptoName}, date, now)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	simulation, err := utils.Simulate(series[0], amount, []time.Time{date})
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	text := fmt.Sprintf("Buying %.2f USD of %s on %s:\n%s", amount, fullCryptoName, utils.FormatDate(date, loc), describeSimulation(simulation))
+
+	// The same amount spread in weekly buys over the same period
+	weeklyDates, _ := utils.BuyDates("weekly", date, now)
+	weekly, err := utils.Simulate(series[0], amount/float64(len(weeklyDates)), weeklyDates)
+	if err == nil {
+		text += fmt.Sprintf("\nSpread in %d weekly buys instead, it would be worth %.2f USD (%+.2f%%)", weekly.Buys, weekly.Value, weekly.Return())
+		text += describeSkipped(weekly)
+	}
+	return simulationResponse(text, simulation, args.Flag("chart"), loc, fields)
+}
+
+// parseSimulationArgs Reads the crypto and the USD amount of a simulation
+func parseSimulationArgs(crypto string, amount string) (string, float64, error) {
+	fullCryptoName, found := utils.GetFullCryptoName(crypto)
+	if !found {
+		return "", 0, fmt.Errorf("I don't support that Crypto ID or it doesn't exist (yet)")
+	}
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil || value <= 0 {
+		return "", 0, fmt.Errorf("That´s not a valid amount! It must be a possitive number of USD")
+	}
+	return fullCryptoName, value, nil
+}
+
+// describeSimulation Sums up the outcome of a simulation
+func describeSimulation(simulation *utils.Simulation) string {
+	text := fmt.Sprintf("Invested %.2f USD in %d buys | %.8f units (avg %.2f USD) | value %.2f USD | return %+.2f%% | max drawdown %.2f%%",
+		simulation.Invested, simulation.Buys, simulation.Units, simulation.AverageCost(),
+		simulation.Value, simulation.Return(), simulation.MaxDrawdown)
+	return text + describeSkipped(simulation)
+}
+
+// describeSkipped Tells about the buys left out because there are no prices for their dates yet
+func describeSkipped(simulation *utils.Simulation) string {
+	if simulation.Skipped == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n%d buys dated after the last price I have were left out", simulation.Skipped)
+}
+
+// simulationResponse Replies with the simulation, adding the invested vs value chart when asked
+func simulationResponse(text string, simulation *utils.Simulation, withChart bool, loc *time.Location, fields []response.Field) *response.Response {
+	if !withChart {
+		return response.New(text, "Here is the simulation", response.Info, fields, "")
+	}
+	config := utils.BuildComparisonChart(simulation.Timestamps, [][]float64{simulation.InvestedSeries, simulation.ValueSeries},
+		[]string{"Invested", "Value"}, "USD", loc, downsample.Default)
+	chart, err := renderChart(config)
+	if err != nil {
+		text += "\nI couldn't draw the chart, please try again"
+		return response.New(text, "Here is the simulation", response.Info, fields, "")
+	}
+	return response.New(text, "Here is the simulation", response.Info, fields, chart)
+}
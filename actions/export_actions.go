@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"crypto-bot/commands"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"fmt"
+	"github.com/slack-go/slack"
+	"strings"
+	"time"
+)
+
+// HandleExport Uploads the market chart of a crypto as a CSV or JSON file:
+// export crypto range csv|json [in currency] [every interval]. The command registry already checked the crypto, the
+// format and the interval
+func HandleExport(api *slack.Client, channel string, thread string, args commands.Values, loc *time.Location, fields []response.Field) *response.Response {
+	currency := "usd"
+	if value, found := args.Option("in"); found {
+		currency = value
+	}
+	var interval time.Duration
+	if value, found := args.Option("every"); found {
+		interval, _ = utils.ParseInterval(value)
+	}
+	format := args.Arg("format")
+	fullCryptoName, _ := utils.GetFullCryptoName(args.Arg("crypto"))
+	timeRange, err := utils.ParseTimeRange(args.List("range"), time.Now(), loc)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+
+	marketChart, err := getMarketChart(fullCryptoName, currency, timeRange.From, timeRange.To)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	if len(marketChart.Prices) == 0 {
+		return response.New(fmt.Sprintf("There is no data for %s in %s for that data range", fullCryptoName, strings.ToUpper(currency)), "I'm Sorry", response.Error, fields, "")
+	}
+	rows := utils.BuildExportRows(marketChart.Prices, marketChart.MarketCaps, marketChart.TotalVolumes)
+	rows = utils.ResampleRows(rows, interval, loc)
+
+	var content []byte
+	if format == "csv" {
+		content, err = utils.ExportCSV(rows, currency)
+	} else {
+		content, err = utils.ExportJSON(rows, fullCryptoName, currency)
+	}
+	if err != nil {
+		return response.New("unexpected error, please try again (Export)", "I'm Sorry", response.Error, fields, "")
+	}
+
+	fileName := fmt.Sprintf("%s_%s_%s_%s.%s", fullCryptoName, strings.ToLower(currency), timeRange.From.Format("20060102"), timeRange.To.Format("20060102"), format)
+	err = uploadFile(api, channel, thread, fileName, format, content)
+	if err != nil {
+		return response.New("I couldn't upload the file, please try again", "I'm Sorry", response.Error, fields, "")
+	}
+
+	text := fmt.Sprintf("I've uploaded %d rows of %s prices in %s", len(rows), fullCryptoName, strings.ToUpper(currency))
+	return response.New(text, "As you wanted", response.Info, fields, "")
+}
+
+// uploadFile Uploads the content as a file to the channel, in the thread unless it's empty
+func uploadFile(api *slack.Client, channel string, thread string, fileName string, fileType string, content []byte) error {
+	_, err := api.UploadFile(slack.FileUploadParameters{
+		Content:         string(content),
+		Filetype:        fileType,
+		Filename:        fileName,
+		Title:           fileName,
+		Channels:        []string{channel},
+		ThreadTimestamp: thread,
+	})
+	return err
+}
@@ -1,6 +1,9 @@
 package actions
 
 import (
+	"crypto-bot/commands"
+	"crypto-bot/render"
+	"crypto-bot/response"
 	"crypto-bot/utils"
 	"fmt"
 	"github.com/slack-go/slack"
@@ -9,33 +12,16 @@ import (
 )
 
 // HandleHello Greet the user
-func HandleHello(user *slack.User, fields []slack.AttachmentField) slack.Attachment {
+func HandleHello(user *slack.User, fields []response.Field) *response.Response {
 	pretext := "Greetings"
 	text := fmt.Sprintf("Hello %s", user.Name)
-	color := "#4af030"
+	kind := response.Success
 
-	return utils.GetAttachment(text, pretext, color, fields, "")
-}
-
-// HandleHelp Gives a set of options to the user
-func HandleHelp(fields []slack.AttachmentField) slack.Attachment {
-	pretext := "Here is all I can do!"
-	text := `Available commands just for you
-		- @CryptoBot hello -> Greet me!
-		- @CryptoBot cryptoList -> Lists cryptos name to show data or set rules
-		- @CryptoBot price any_crypto_name -> Gets the current price of the crypto (if it exists)
-		- @CryptoBot chart any_crypto_name DD-MM-AAAA DD-MM-AAAAA -> Gets the historical market price within a range of dates
-		- @CrypyoBot chart any_crypto_name 24h/30d/1y -> Gets the historical market price for last 24 hours, 30 days or 1 year.
-		- @CryptoBot setHigh any_crypto_name high_value -> Set a value so I can tell you when the crypto surpasses it
-		- @CryptoBot setLow any_crypto_name low_value-> Set a value so I can tell you when the crypto is lower than it
-		More to come!`
-	color := "#0000ff"
-
-	return utils.GetAttachment(text, pretext, color, fields, "")
+	return response.New(text, pretext, kind, fields, "")
 }
 
 // HandleCryptoList Gives a list of the allowed crypto names
-func HandleCryptoList(fields []slack.AttachmentField) slack.Attachment {
+func HandleCryptoList(fields []response.Field) *response.Response {
 	pretext := "Here goes a list of cryptos you might be interested in"
 	text := `Feel free to use either the fullname or the abreviation!
 				BTC - bitcoin
@@ -45,42 +31,67 @@ func HandleCryptoList(fields []slack.AttachmentField) slack.Attachment {
 				DOT - polkadot
 				UNI - uniswap
 				AAVE - aave`
-	color := "#0000ff"
+	kind := response.Info
 
-	return utils.GetAttachment(text, pretext, color, fields, "")
+	return response.New(text, pretext, kind, fields, "")
+}
+
+// HandlePrice Gives crypto price, the crypto was already checked by the command registry
+func HandlePrice(args commands.Values, fields []response.Field) *response.Response {
+	crypto := args.Arg("crypto")
+	abbreviatedCryptoName, _ := utils.GetAbbreviatedCryptoName(crypto)
+	price, err := utils.GetCryptoValue(abbreviatedCryptoName, "USD")
+	if err != nil {
+		log.Println("Error getting the price of", abbreviatedCryptoName, err)
+		return response.New(fmt.Sprintf("I couldn't get the price of %s, please try again", abbreviatedCryptoName), "I'm Sorry", response.Error, fields, "")
+	}
+	text := fmt.Sprintf("1 "+abbreviatedCryptoName+" equals to %s USD", price)
+	reply := response.New(text, "As you wanted", response.Highlight, fields, "")
+	return addMarketButtons(reply, crypto, nil, Price+" "+crypto)
 }
 
-// HandlePrice Gives crypto price
-func HandlePrice(splitedText []string, fields []slack.AttachmentField) slack.Attachment {
-	if len(splitedText) < 3 {
-		return utils.GetAttachment("You didn't enter any crypto id", "I'm Sorry", "#ff0000", fields, "")
-	} else {
-		abbreviatedCryptoName, found := utils.GetAbbreviatedCryptoName(splitedText[2])
-		if !found {
-			return utils.GetAttachment("I don't support that crypto ID or it doesn't exist (yet)", "I'm Sorry", "#ff0000", fields, "")
-		} else {
-			price := utils.GetCryptoValue(abbreviatedCryptoName, "USD")
-			text := fmt.Sprintf("1 "+abbreviatedCryptoName+" equals to %s USD", price)
-			return utils.GetAttachment(text, "As you wanted", "#ff8000", fields, "")
+// HandleTimezone Shows, sets (timezone Europe/Madrid) or resets (timezone reset) the user timezone
+func HandleTimezone(args commands.Values, userName string, profileTZ string, fields []response.Field) *response.Response {
+	timezone := args.Arg("timezone|reset")
+	if timezone == "" {
+		loc := utils.UserLocation(userName, profileTZ)
+		text := fmt.Sprintf("I'm showing you dates in %s, it's %s", loc, utils.GetFormattedActualDate(loc))
+		return response.New(text, "Here you go", response.Info, fields, "")
+	}
+
+	if timezone == "reset" {
+		err := utils.ResetUserTimezone(userName)
+		if err != nil {
+			return response.New("Please try again", "I'm Sorry", response.Error, fields, "")
 		}
+		text := fmt.Sprintf("Back to %s, taken from your Slack profile or my default", utils.UserLocation(userName, profileTZ))
+		return response.New(text, "Done!", response.Success, fields, "")
+	}
+
+	// Timezone names are case sensitive, but the mention text was lowered
+	loc, err := utils.SetUserTimezone(userName, utils.TimezoneName(timezone))
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
 	}
+	text := fmt.Sprintf("From now on I'll show you dates in %s, it's %s", loc, utils.GetFormattedActualDate(loc))
+	return response.New(text, "Done!", response.Success, fields, "")
 }
 
 // InitMessage Initial message when the bot starts running
 func InitMessage(api *slack.Client) error {
 
-	date := utils.GetFormattedActualDate()
+	date := utils.GetFormattedActualDate(utils.DefaultLocation())
 
 	text := fmt.Sprintf("Hi! I'm on! Type help after tagging me to know what I can do!")
-	fields := []slack.AttachmentField{
+	fields := []response.Field{
 		{
 			Title: "Date",
 			Value: date,
 		},
 	}
-	attachment := utils.GetAttachment(text, "Howdy!", "#4af030", fields, "")
+	reply := response.New(text, "Howdy!", response.Success, fields, "")
 
-	_, _, err := api.PostMessage(os.Getenv("SLACK_CHANNEL_ID"), slack.MsgOptionAttachments(attachment))
+	_, _, err := api.PostMessage(os.Getenv("SLACK_CHANNEL_ID"), render.MsgOptions(reply)...)
 
 	if err != nil {
 		log.Println("Error sending message to Slack! ", err)
@@ -0,0 +1,99 @@
+package actions
+
+import (
+	"crypto-bot/commands"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"fmt"
+	"strings"
+)
+
+// HandleHelp Lists the commands by category, or details one of them with help command [subcommand...]
+func HandleHelp(args commands.Values, fields []response.Field) *response.Response {
+	names := args.List("command")
+	if len(names) > 0 {
+		command, found := commands.Lookup(names[0])
+		if found {
+			for _, name := range names[1:] {
+				subcommand, found := findSubcommand(command, name)
+				if !found {
+					break
+				}
+				command = subcommand
+			}
+			return commandHelp(command, fields)
+		}
+		help := generalHelp(fields)
+		help.Text = fmt.Sprintf("I don't know the command *%s*, these are the ones I do", names[0])
+		return help
+	}
+	return generalHelp(fields)
+}
+
+// generalHelp A section per category with the usage and summary of its commands
+func generalHelp(fields []response.Field) *response.Response {
+	help := response.New("Mention me with a command (`@CryptoBot price btc`) or use it as `/crypto price btc`. Type `help command` to know more about one of them",
+		"Here is all I can do!", response.Info, fields, "")
+	byCategory := make(map[string][]*commands.Command)
+	for _, command := range commands.All() {
+		byCategory[command.Category] = append(byCategory[command.Category], command)
+	}
+	for _, category := range helpCategories {
+		var lines []string
+		for _, command := range byCategory[category] {
+			lines = append(lines, fmt.Sprintf("• `%s` %s", utils.EscapeText(command.Usage()), command.Summary))
+		}
+		help.AddSection(response.Section{Title: category, Text: strings.Join(lines, "\n")})
+	}
+	return help
+}
+
+// commandHelp Usage, options, examples and permissions of a command
+func commandHelp(command *commands.Command, fields []response.Field) *response.Response {
+	permissions := command.Permissions
+	if permissions == "" {
+		permissions = "Anyone"
+	}
+	details := []response.Field{
+		{Title: "Usage", Value: fmt.Sprintf("`%s`", utils.EscapeText(command.Usage()))},
+		{Title: "Permissions", Value: permissions},
+	}
+	if len(command.Aliases) > 0 {
+		details = append(details, response.Field{Title: "Aliases", Value: strings.Join(command.Aliases, ", ")})
+	}
+
+	help := response.New(command.Summary, command.FullName(), response.Info, fields, "")
+	help.AddSection(response.Section{Fields: details})
+	if len(command.Subcommands) > 0 {
+		var lines []string
+		for _, subcommand := range command.Subcommands {
+			lines = append(lines, fmt.Sprintf("• `%s` %s", utils.EscapeText(subcommand.Usage()), subcommand.Summary))
+		}
+		help.AddSection(response.Section{Title: "Subcommands", Text: strings.Join(lines, "\n")})
+	}
+	if len(command.Flags) > 0 {
+		var lines []string
+		for _, flag := range command.Flags {
+			lines = append(lines, fmt.Sprintf("• `+%s` %s", flag.Name, flag.Description))
+		}
+		help.AddSection(response.Section{Title: "Options", Text: strings.Join(lines, "\n")})
+	}
+	if len(command.Examples) > 0 {
+		var lines []string
+		for _, example := range command.Examples {
+			lines = append(lines, fmt.Sprintf("• `@CryptoBot %s`", example))
+		}
+		help.AddSection(response.Section{Title: "Examples", Text: strings.Join(lines, "\n")})
+	}
+	return help
+}
+
+// findSubcommand The subcommand of the command with that name
+func findSubcommand(command *commands.Command, name string) (*commands.Command, bool) {
+	for _, subcommand := range command.Subcommands {
+		if subcommand.Name == name {
+			return subcommand, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,203 @@
+package actions
+
+import (
+	"crypto-bot/render"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// DeleteAlertAction Button of the Home tab that deletes an alert, its value is the alert ID
+const DeleteAlertAction = "delete_alert"
+
+const (
+	// maxHomeAlerts Active alerts listed in the Home tab, Slack allows 100 blocks in a view
+	maxHomeAlerts = 20
+	// maxFiredAlerts Fired or expired alerts listed in the Home tab
+	maxFiredAlerts = 5
+	// recentAlerts How long a fired or expired alert stays in the Home tab
+	recentAlerts = 7 * 24 * time.Hour
+)
+
+// PublishHome Shows the user a dashboard of their alerts, watchlist and portfolio in the Home tab
+func PublishHome(api *slack.Client, user *slack.User) error {
+	err := utils.SaveHomeUser(user.Name, user.ID)
+	if err != nil {
+		// The Home tab is still shown, it just won't be refreshed when the alerts change
+		log.Println("Error saving the Home tab user", user.Name, err)
+	}
+	_, err = api.PublishView(user.ID, render.HomeTab(homeResponse(user)), "")
+	return err
+}
+
+// RefreshHome Publishes the Home tab again after something it shows changed, if the user has opened it
+func RefreshHome(api *slack.Client, userName string) {
+	userID, found := utils.GetHomeUser(userName)
+	if !found {
+		return
+	}
+	// The profile is read again, the user may have changed their timezone
+	user, err := api.GetUserInfo(userID)
+	if err == nil {
+		err = PublishHome(api, user)
+	}
+	if err != nil {
+		log.Println("Error refreshing the Home tab of", userName, err)
+	}
+}
+
+// HandleDeleteAlert Deletes an alert of the user from the Home tab, and shows the Home tab without it
+func HandleDeleteAlert(api *slack.Client, user *slack.User, id string) error {
+	err := utils.DeleteRule(user.Name, id)
+	if err != nil {
+		// It was already deleted, the Home tab was just out of date
+		log.Println("Error deleting the alert of", user.Name, err)
+	}
+	return PublishHome(api, user)
+}
+
+// homeResponse The dashboard of the user
+func homeResponse(user *slack.User) *response.Response {
+	loc := utils.UserLocation(user.Name, user.TZ)
+	footer := []response.Field{{Title: "Updated", Value: utils.GetFormattedActualDate(loc)}}
+	reply := response.New("", "Your crypto dashboard", response.Info, footer, "")
+	reply.AddSection(alertsSection(user.Name, loc, false))
+	reply.AddSection(alertsSection(user.Name, loc, true))
+	reply.AddSection(homeWatchlistSection(user))
+	reply.AddSection(homePortfolioSection(user.Name))
+	return reply.AddButton(response.Button{Text: "New alert", ActionID: SetAlertAction, Primary: true})
+}
+
+// alertsSection The active alerts of the user with their delete button, or the ones that fired or expired recently
+func alertsSection(userName string, loc *time.Location, closed bool) response.Section {
+	section := response.Section{Title: "Your alerts"}
+	if closed {
+		section.Title = "Recently fired or expired"
+	}
+	rules, err := utils.LoadUserRules(userName)
+	if err != nil {
+		section.Text = "I couldn't read your alerts, please try again"
+		return section
+	}
+
+	var listed []*utils.Rule
+	for _, rule := range rules {
+		if rule.Status() == utils.ActiveRule && !closed {
+			listed = append(listed, rule)
+		}
+		if closedAt, found := rule.ClosedAt(); found && closed && time.Since(closedAt) < recentAlerts {
+			listed = append(listed, rule)
+		}
+	}
+	limit := maxHomeAlerts
+	if closed {
+		// The most recent first
+		sort.SliceStable(listed, func(i, j int) bool {
+			a, _ := listed[i].ClosedAt()
+			b, _ := listed[j].ClosedAt()
+			return a.After(b)
+		})
+		limit = maxFiredAlerts
+	}
+
+	if len(listed) == 0 {
+		section.Text = "None in the last 7 days"
+		if !closed {
+			section.Text = "You don't have active alerts, set one with the button below"
+		}
+		return section
+	}
+	for i, rule := range listed {
+		if i == limit {
+			section.Text = fmt.Sprintf("Showing %d of %d", limit, len(listed))
+			break
+		}
+		item := response.Item{Text: describeRule(rule, loc)}
+		if !closed {
+			item.Button = &response.Button{Text: "Delete", ActionID: DeleteAlertAction, Value: rule.ID()}
+		}
+		section.Items = append(section.Items, item)
+	}
+	return section
+}
+
+// describeRule One line about the alert, what it waits for and when it fired or expires
+func describeRule(rule *utils.Rule, loc *time.Location) string {
+	direction := "over"
+	if rule.Rule().String() == "lowLimit" {
+		direction = "under"
+	}
+	text := fmt.Sprintf("*%s* %s %s USD", rule.Crypto(), direction, strconv.FormatFloat(rule.Price(), 'f', -1, 64))
+	closedAt, closed := rule.ClosedAt()
+	switch {
+	case closed && rule.Status() == utils.ExpiredRule:
+		text += ", expired on " + utils.FormatDate(closedAt, loc)
+	case closed && rule.Status() == utils.UndeliverableRule:
+		text += ", fired on " + utils.FormatDate(closedAt, loc) + " but I couldn't tell you"
+	case closed:
+		text += ", fired on " + utils.FormatDate(closedAt, loc)
+	default:
+		text += ", set on " + utils.FormatRuleDate(rule.ID(), loc)
+		if expires, found := rule.ExpiresAt(); found {
+			text += ", until " + utils.FormatDate(expires, loc)
+		}
+	}
+	if rule.Direct() {
+		text += ", by direct message"
+	}
+	return text
+}
+
+// homeWatchlistSection The watchlist of the user with the current prices
+func homeWatchlistSection(user *slack.User) response.Section {
+	section := response.Section{Title: "Your watchlist"}
+	watchlist, err := utils.GetWatchlist(utils.UserWatchlist, user.ID, user.Name)
+	if err != nil {
+		section.Text = "I couldn't read your watchlist, please try again"
+		return section
+	}
+	if len(watchlist.Cryptos) == 0 {
+		section.Text = "Your watchlist is empty, try watch add btc eth sol"
+		return section
+	}
+	var sb strings.Builder
+	for _, crypto := range watchlist.Cryptos {
+		price, err := utils.GetCryptoValue(crypto, "USD")
+		if err != nil {
+			sb.WriteString(fmt.Sprintf("*%s*  price unavailable\n", crypto))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("*%s*  %s USD\n", crypto, price))
+	}
+	section.Text = sb.String()
+	return section
+}
+
+// homePortfolioSection The holdings of the user with their value and P&L
+func homePortfolioSection(userName string) response.Section {
+	section := response.Section{Title: "Your portfolio"}
+	transactions, err := utils.LoadTransactions(userName)
+	if err != nil {
+		section.Text = "I couldn't read your transactions, please try again"
+		return section
+	}
+	if len(transactions) == 0 {
+		section.Text = "You don't have any transactions yet, try: buy 0.5 btc at 30000"
+		return section
+	}
+	holdings, err := utils.ComputeHoldings(transactions)
+	if err == nil {
+		section.Text, err = describeHoldings(holdings)
+	}
+	if err != nil {
+		section.Text = err.Error()
+	}
+	return section
+}
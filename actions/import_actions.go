@@ -0,0 +1,143 @@
+package actions
+
+import (
+	"bytes"
+	"crypto-bot/importers"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"fmt"
+	"github.com/slack-go/slack"
+	"sort"
+	"strings"
+)
+
+// maxReportedErrors Rows with errors listed in the reply, the rest are only counted
+const maxReportedErrors = 5
+
+// HandleImport Imports into the portfolio the trades of the CSV files attached to the message that mentioned the bot.
+// The message is the one of the event when it is known, otherwise it is read from the channel or the thread
+func HandleImport(api *slack.Client, channel string, timestamp string, thread string, message *slack.Message, userID string, userName string, fields []response.Field) *response.Response {
+	var err error
+	if message == nil {
+		message, err = getMessage(api, channel, timestamp, thread)
+		if err != nil {
+			return response.New("I couldn't read your message, please try again", "I'm Sorry", response.Error, fields, "")
+		}
+	}
+	// Only the files of the requester's own message go into their portfolio
+	var files []slack.File
+	if message != nil && message.Timestamp == timestamp && message.User == userID {
+		files = message.Files
+	}
+	if len(files) == 0 {
+		text := fmt.Sprintf("Upload a CSV with your trades and mention me with import in the same message. I can read exports from %s", strings.Join(importers.Formats(), ", "))
+		return response.New(text, "There is no file to import", response.Highlight, fields, "")
+	}
+
+	var report strings.Builder
+	for _, file := range files {
+		report.WriteString(importFile(api, file, userName) + "\n")
+	}
+	return response.New(report.String(), "Import finished", response.Info, fields, "")
+}
+
+// getMessage Gets the message with its files, app mentions don't include them. The history of the channel only has
+// the top level messages, replies are read from their thread. Nil when there is no message at the timestamp
+func getMessage(api *slack.Client, channel string, timestamp string, thread string) (*slack.Message, error) {
+	if timestamp == "" {
+		return nil, nil
+	}
+
+	var messages []slack.Message
+	var err error
+	if thread != "" {
+		messages, _, _, err = api.GetConversationReplies(&slack.GetConversationRepliesParameters{
+			ChannelID: channel,
+			Timestamp: thread,
+			Oldest:    timestamp,
+			Latest:    timestamp,
+			Inclusive: true,
+		})
+	} else {
+		var history *slack.GetConversationHistoryResponse
+		history, err = api.GetConversationHistory(&slack.GetConversationHistoryParameters{
+			ChannelID: channel,
+			Oldest:    timestamp,
+			Latest:    timestamp,
+			Inclusive: true,
+			Limit:     1,
+		})
+		if history != nil {
+			messages = history.Messages
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The parent of the thread always comes first in the replies
+	for i := range messages {
+		if messages[i].Timestamp == timestamp {
+			return &messages[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// importFile Imports one file, returning the line of the report about it
+func importFile(api *slack.Client, file slack.File, userName string) string {
+	var content bytes.Buffer
+	err := api.GetFile(file.URLPrivateDownload, &content)
+	if err != nil {
+		return fmt.Sprintf("%s: I couldn't download it", file.Name)
+	}
+
+	format, records, rowErrors, err := importers.Parse(&content)
+	if err != nil {
+		return fmt.Sprintf("%s: %s", file.Name, err)
+	}
+
+	var transactions []utils.Transaction
+	unknownAssets := make(map[string]int)
+	for _, record := range records {
+		crypto, found := utils.GetAbbreviatedCryptoName(record.Asset)
+		if !found {
+			unknownAssets[record.Asset]++
+			continue
+		}
+		transactions = append(transactions, utils.Transaction{
+			User:     userName,
+			Date:     record.Date,
+			Side:     record.Side,
+			Crypto:   crypto,
+			Quantity: record.Quantity,
+			Price:    record.Price,
+			Ref:      strings.Replace(record.Ref, "|", "_", -1),
+		})
+	}
+
+	imported, duplicates, err := utils.ImportTransactions(userName, transactions)
+	if err != nil {
+		return fmt.Sprintf("%s (%s): nothing was imported, %s", file.Name, format, err)
+	}
+
+	text := fmt.Sprintf("%s (%s): %d trades imported, %d already imported before", file.Name, format, imported, duplicates)
+	if len(unknownAssets) > 0 {
+		var assets []string
+		for asset, count := range unknownAssets {
+			assets = append(assets, fmt.Sprintf("%s (%d)", asset, count))
+		}
+		sort.Strings(assets)
+		text += fmt.Sprintf("\nSkipped trades of assets I don't support: %s", strings.Join(assets, ", "))
+	}
+	if len(rowErrors) > 0 {
+		text += fmt.Sprintf("\n%d rows couldn't be read:", len(rowErrors))
+		for i, rowError := range rowErrors {
+			if i == maxReportedErrors {
+				text += fmt.Sprintf("\n  ... and %d more", len(rowErrors)-maxReportedErrors)
+				break
+			}
+			text += "\n  " + rowError.Error()
+		}
+	}
+	return text
+}
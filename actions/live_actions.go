@@ -0,0 +1,184 @@
+package actions
+
+import (
+	"crypto-bot/commands"
+	"crypto-bot/render"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// LiveCancelAction Button that stops a live price message, its value is the ID of the live session
+const LiveCancelAction = "live_cancel"
+
+const (
+	defaultLiveDuration = 15 * time.Minute
+	maxLiveDuration     = time.Hour
+	defaultLiveInterval = 30 * time.Second
+	// minLiveInterval Keeps the price provider and chat.update within their rate limits
+	minLiveInterval = 10 * time.Second
+)
+
+// liveSession A price message being refreshed, stopped by closing stop
+type liveSession struct {
+	userID string
+	stop   chan struct{}
+}
+
+var (
+	liveSessionsMutex sync.Mutex
+	liveSessions      = make(map[string]*liveSession)
+)
+
+// HandleLive Posts the price of the crypto and refreshes it with the change since the start, every interval and
+// until the duration ends or its cancel button is pressed: live btc 15m [every 30s]. Each user has one live price at
+// a time, starting another one stops the previous one
+func HandleLive(api *slack.Client, channel string, thread string, userID string, args commands.Values, loc *time.Location, fields []response.Field) *response.Response {
+	abbreviatedCryptoName, _ := utils.GetAbbreviatedCryptoName(args.Arg("crypto"))
+	duration, interval, err := parseLiveArgs(args)
+	if err != nil {
+		return response.New(err.Error(), "Try again!", response.Highlight, fields, "")
+	}
+	start, err := livePrice(abbreviatedCryptoName)
+	if err != nil {
+		return response.New(fmt.Sprintf("I couldn't get the price of %s, please try again", abbreviatedCryptoName), "I'm Sorry", response.Error, fields, "")
+	}
+
+	id := strconv.FormatInt(time.Now().UnixNano(), 10)
+	end := time.Now().Add(duration)
+	reply := liveResponse(abbreviatedCryptoName, start, start, end, loc, fields, id)
+	options := render.MsgOptions(reply)
+	if thread != "" {
+		options = append(options, slack.MsgOptionTS(thread))
+	}
+	_, timestamp, err := api.PostMessage(channel, options...)
+	if err != nil {
+		return response.New("I can only post live prices where I am, invite me or mention me in the channel", "I'm Sorry", response.Error, fields, "")
+	}
+
+	session := &liveSession{userID: userID, stop: make(chan struct{})}
+	liveSessionsMutex.Lock()
+	for previousID, previous := range liveSessions {
+		if previous.userID == userID {
+			close(previous.stop)
+			delete(liveSessions, previousID)
+		}
+	}
+	liveSessions[id] = session
+	liveSessionsMutex.Unlock()
+	go runLive(api, channel, timestamp, id, session, abbreviatedCryptoName, start, end, interval, loc, fields)
+	// The message is already posted
+	return nil
+}
+
+// StopLive Stops the live price message of the session, only who started it can stop it
+func StopLive(id string, userID string) error {
+	liveSessionsMutex.Lock()
+	defer liveSessionsMutex.Unlock()
+	session, found := liveSessions[id]
+	if !found {
+		return fmt.Errorf("That live price already ended")
+	}
+	if session.userID != userID {
+		return fmt.Errorf("Only who started the live price can stop it")
+	}
+	close(session.stop)
+	delete(liveSessions, id)
+	return nil
+}
+
+// runLive Refreshes the live price message until it ends or is stopped, then leaves it with the last price
+func runLive(api *slack.Client, channel string, timestamp string, id string, session *liveSession, crypto string, start float64, end time.Time, interval time.Duration, loc *time.Location, fields []response.Field) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	last := start
+	for {
+		select {
+		case <-session.stop:
+			reply := liveResponse(crypto, start, last, end, loc, fields, "")
+			reply.Title = fmt.Sprintf("%s live price, stopped", crypto)
+			updateLive(api, channel, timestamp, reply)
+			return
+		case now := <-ticker.C:
+			price, err := livePrice(crypto)
+			if err == nil {
+				last = price
+			} else {
+				// The message keeps the last price until the provider answers again
+				log.Println("Error getting the live price of", crypto, err)
+			}
+			if !now.Before(end) {
+				liveSessionsMutex.Lock()
+				delete(liveSessions, id)
+				liveSessionsMutex.Unlock()
+				reply := liveResponse(crypto, start, last, end, loc, fields, "")
+				reply.Title = fmt.Sprintf("%s live price, ended", crypto)
+				updateLive(api, channel, timestamp, reply)
+				return
+			}
+			updateLive(api, channel, timestamp, liveResponse(crypto, start, last, end, loc, fields, id))
+		}
+	}
+}
+
+// livePrice The current USD price of the crypto
+func livePrice(crypto string) (float64, error) {
+	value, err := utils.GetCryptoValue(crypto, "USD")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// updateLive Replaces the live price message
+func updateLive(api *slack.Client, channel string, timestamp string, reply *response.Response) {
+	_, _, _, err := api.UpdateMessage(channel, timestamp, render.MsgOptions(reply)...)
+	if err != nil {
+		log.Println("Error updating the live price", err)
+	}
+}
+
+// liveResponse The price and its change since the start. The cancel button is only shown while id isn't empty
+func liveResponse(crypto string, start float64, price float64, end time.Time, loc *time.Location, fields []response.Field, id string) *response.Response {
+	change := price - start
+	text := fmt.Sprintf("1 %s equals to %s USD\nChange since start: %+.2f USD (%+.2f%%)\nUpdated %s",
+		crypto, strconv.FormatFloat(price, 'f', -1, 64), change, change/start*100, utils.GetFormattedActualDate(loc))
+	kind := response.Muted
+	if id != "" {
+		text += ", until " + utils.FormatDate(end, loc)
+		kind = response.Highlight
+	}
+	reply := response.New(text, fmt.Sprintf("%s live price", crypto), kind, fields, "")
+	if id != "" {
+		reply.AddButton(response.Button{Text: "Cancel", ActionID: LiveCancelAction, Value: id})
+	}
+	return reply
+}
+
+// parseLiveArgs Reads the optional duration and refresh interval: [15m] [every 30s]. The command registry already
+// checked they are valid durations
+func parseLiveArgs(args commands.Values) (time.Duration, time.Duration, error) {
+	duration, interval := defaultLiveDuration, defaultLiveInterval
+	if value := args.Arg("duration"); value != "" {
+		duration, _ = utils.ParseInterval(value)
+	}
+	if value, found := args.Option("every"); found {
+		interval, _ = utils.ParseInterval(value)
+	}
+	if duration > maxLiveDuration {
+		return 0, 0, fmt.Errorf("Live prices last at most an hour")
+	}
+	if interval < minLiveInterval {
+		return 0, 0, fmt.Errorf("I can refresh the price at most every %s", minLiveInterval)
+	}
+	if interval > duration {
+		return 0, 0, fmt.Errorf("The interval must be shorter than the duration")
+	}
+	return duration, interval, nil
+}
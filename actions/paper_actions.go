@@ -0,0 +1,292 @@
+package actions
+
+import (
+	"crypto-bot/commands"
+	"crypto-bot/decimal"
+	"crypto-bot/render"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"fmt"
+	"github.com/slack-go/slack"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxClosedOrders Filled, cancelled or rejected orders listed by paper orders, the most recent ones
+const maxClosedOrders = 5
+
+// HandlePaper Paper trading with a virtual balance:
+// paper [positions|orders|leaderboard|reset], paper buy|sell quantity crypto [limit|stop price], paper cancel id.
+// The command registry already checked the subcommand and its arguments
+func HandlePaper(args commands.Values, userName string, channel string, loc *time.Location, fields []response.Field) *response.Response {
+	switch args.Subcommand {
+	case utils.Buy, utils.Sell:
+		return handlePaperOrder(args, userName, channel, fields)
+	case "positions":
+		return handlePaperAccount(userName, true, fields)
+	case "orders":
+		return handlePaperOrders(userName, loc, fields)
+	case "cancel":
+		id, _ := strconv.Atoi(strings.TrimPrefix(args.Arg("order"), "#"))
+		err := utils.CancelPaperOrder(userName, id)
+		if err != nil {
+			return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+		}
+		return response.New(fmt.Sprintf("Order #%d cancelled", id), "Done!", response.Success, fields, "")
+	case "leaderboard":
+		return handlePaperLeaderboard(channel, fields)
+	case "reset":
+		err := utils.ResetPaperAccount(userName)
+		if err != nil {
+			return response.New("Please try again", "I'm Sorry", response.Error, fields, "")
+		}
+		text := fmt.Sprintf("Your paper account starts again with %s USD", utils.PaperStartingBalance.StringFixed(2))
+		return response.New(text, "Done!", response.Success, fields, "")
+	default:
+		return handlePaperAccount(userName, false, fields)
+	}
+}
+
+// handlePaperOrder Places a market order (filled now at the current price), or a limit or stop order that the rules
+// loop fills when the price crosses it: paper buy 0.5 btc [limit|stop 30000]. The command registry already checked the
+// crypto and that the quantity and the price are numbers
+func handlePaperOrder(args commands.Values, userName string, channel string, fields []response.Field) *response.Response {
+	side := args.Subcommand
+	quantity, err := decimal.NewFromString(args.Arg("quantity"))
+	if err != nil || quantity.Sign() <= 0 {
+		return response.New("That´s not a valid quantity! It must be a possitive number", "Try again!", response.Highlight, fields, "")
+	}
+	abbreviatedCryptoName, _ := utils.GetAbbreviatedCryptoName(args.Arg("crypto"))
+	marketPrice, err := getCurrentPrice(abbreviatedCryptoName)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+
+	order := utils.PaperOrder{
+		User:     userName,
+		Channel:  channel,
+		Date:     time.Now(),
+		Side:     side,
+		Type:     utils.MarketOrder,
+		Crypto:   abbreviatedCryptoName,
+		Quantity: quantity,
+	}
+	for _, orderType := range []string{utils.LimitOrder, utils.StopOrder} {
+		value, found := args.Option(orderType)
+		if !found {
+			continue
+		}
+		order.Type = orderType
+		order.Price, err = decimal.NewFromString(value)
+		if err != nil || order.Price.Sign() <= 0 {
+			return response.New("That´s not a valid price! It must be a possitive number", "Try again!", response.Highlight, fields, "")
+		}
+	}
+
+	placed, err := utils.PlacePaperOrder(order, marketPrice)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	if placed.Status == utils.OrderFilled {
+		return response.New(describePaperFill(placed), "Filled!", response.Highlight, fields, "")
+	}
+	text := fmt.Sprintf("Order #%d placed: %s %s %s %s at %s USD. %s is at %s USD now",
+		placed.ID, placed.Type, placed.Side, placed.Quantity, placed.Crypto, placed.Price, placed.Crypto, marketPrice)
+	return response.New(text, "Noted!", response.Highlight, fields, "")
+}
+
+// handlePaperAccount Shows the cash, the value and return of the paper account, and its positions when asked
+func handlePaperAccount(userName string, withPositions bool, fields []response.Field) *response.Response {
+	orders, err := utils.LoadPaperOrders(userName)
+	if err != nil {
+		return response.New("I couldn't read your paper account, please try again", "I'm Sorry", response.Error, fields, "")
+	}
+	account, err := utils.ComputePaperAccount(userName, orders)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+
+	var sb strings.Builder
+	positionsValue := decimal.Zero
+	prices := make(map[string]decimal.Decimal)
+	for _, holding := range account.Holdings {
+		if holding.Quantity.IsZero() {
+			continue
+		}
+		price, err := cachedPrice(prices, holding.Crypto)
+		if err != nil {
+			return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+		}
+		value := holding.Quantity.Mul(price)
+		positionsValue = positionsValue.Add(value)
+		if withPositions {
+			sb.WriteString(fmt.Sprintf("%s %s: avg %s USD | value %s USD | unrealized %s\n",
+				holding.Quantity, holding.Crypto, holding.AverageCost().StringFixed(2), value.StringFixed(2), formatPnL(value.Sub(holding.CostBasis), holding.CostBasis)))
+		}
+	}
+	if withPositions && positionsValue.IsZero() {
+		sb.WriteString("You don't have any positions, try: paper buy 0.5 btc\n")
+	}
+
+	total := account.Cash.Add(positionsValue)
+	sb.WriteString(fmt.Sprintf("Cash %s USD | positions %s USD | total %s USD | return %s",
+		account.Cash.StringFixed(2), positionsValue.StringFixed(2), total.StringFixed(2), formatPnL(total.Sub(utils.PaperStartingBalance), utils.PaperStartingBalance)))
+	return response.New(sb.String(), "Here is your paper account", response.Info, fields, "")
+}
+
+// handlePaperOrders Lists the open orders of the user and the last ones that were closed
+func handlePaperOrders(userName string, loc *time.Location, fields []response.Field) *response.Response {
+	orders, err := utils.LoadPaperOrders(userName)
+	if err != nil {
+		return response.New("I couldn't read your paper orders, please try again", "I'm Sorry", response.Error, fields, "")
+	}
+	if len(orders) == 0 {
+		return response.New("You haven't placed any paper orders yet, try: paper buy 0.5 btc limit 30000", "Nothing here", response.Muted, fields, "")
+	}
+
+	var open, closed []string
+	for _, order := range orders {
+		if order.Status == utils.OrderOpen {
+			open = append(open, fmt.Sprintf("#%d %s %s %s %s at %s USD (since %s)",
+				order.ID, order.Type, order.Side, order.Quantity, order.Crypto, order.Price, utils.FormatDate(order.Date, loc)))
+			continue
+		}
+		line := fmt.Sprintf("#%d %s %s %s %s: %s", order.ID, order.Type, order.Side, order.Quantity, order.Crypto, order.Status)
+		if order.Status == utils.OrderFilled {
+			line += fmt.Sprintf(" at %s USD on %s", order.FillPrice, utils.FormatDate(order.FilledAt, loc))
+		}
+		closed = append(closed, line)
+	}
+	if len(closed) > maxClosedOrders {
+		closed = closed[len(closed)-maxClosedOrders:]
+	}
+
+	text := "Open orders:\n"
+	if len(open) == 0 {
+		text += "none\n"
+	}
+	text += strings.Join(open, "\n")
+	if len(closed) > 0 {
+		text += "\nLast closed orders:\n" + strings.Join(closed, "\n")
+	}
+	return response.New(text, "Here are your paper orders", response.Info, fields, "")
+}
+
+// handlePaperLeaderboard Ranks by return the paper accounts of who traded in the channel
+func handlePaperLeaderboard(channel string, fields []response.Field) *response.Response {
+	orders, err := utils.LoadPaperOrders("")
+	if err != nil {
+		return response.New("I couldn't read the paper accounts, please try again", "I'm Sorry", response.Error, fields, "")
+	}
+
+	type entry struct {
+		user  string
+		total decimal.Decimal
+	}
+	var entries []entry
+	seen := make(map[string]bool)
+	prices := make(map[string]decimal.Decimal)
+	for _, order := range orders {
+		if order.Channel != channel || seen[order.User] {
+			continue
+		}
+		seen[order.User] = true
+		account, err := utils.ComputePaperAccount(order.User, orders)
+		if err != nil {
+			return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+		}
+		total := account.Cash
+		for _, holding := range account.Holdings {
+			if holding.Quantity.IsZero() {
+				continue
+			}
+			price, err := cachedPrice(prices, holding.Crypto)
+			if err != nil {
+				return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+			}
+			total = total.Add(holding.Quantity.Mul(price))
+		}
+		entries = append(entries, entry{order.User, total})
+	}
+	if len(entries) == 0 {
+		return response.New("Nobody has paper traded in this channel yet, try: paper buy 0.5 btc", "Nothing here", response.Muted, fields, "")
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].total.GreaterThan(entries[j].total)
+	})
+	var sb strings.Builder
+	for i, e := range entries {
+		sb.WriteString(fmt.Sprintf("%d. %s: %s USD, return %s\n", i+1, e.user, e.total.StringFixed(2),
+			formatPnL(e.total.Sub(utils.PaperStartingBalance), utils.PaperStartingBalance)))
+	}
+	return response.New(sb.String(), "Paper trading leaderboard", response.Info, fields, "")
+}
+
+// VerifyPaperOrders Fills the limit and stop orders whose price was crossed and lets their channel know. Only failing
+// to process the orders is returned, announcements that can't be posted are logged
+func VerifyPaperOrders(api *slack.Client) error {
+	orders, err := utils.LoadPaperOrders("")
+	if err != nil {
+		return err
+	}
+	prices := make(map[string]decimal.Decimal)
+	failed := make(map[string]bool)
+	for _, order := range orders {
+		if order.Status != utils.OrderOpen || failed[order.Crypto] {
+			continue
+		}
+		if _, err := cachedPrice(prices, order.Crypto); err != nil {
+			failed[order.Crypto] = true
+			// Its orders are checked again on the next tick
+			log.Println("Error getting the price of", order.Crypto, "for the paper orders", err)
+		}
+	}
+	if len(prices) == 0 {
+		return nil
+	}
+
+	changed, err := utils.ProcessPaperOrders(prices)
+	if err != nil {
+		return err
+	}
+
+	for _, order := range changed {
+		text := describePaperFill(order)
+		pretext, kind := "Paper order filled!", response.Success
+		if order.Status == utils.OrderRejected {
+			text = fmt.Sprintf("%s, your order #%d (%s %s %s %s) was rejected: the account couldn't pay for it when the price was reached",
+				order.User, order.ID, order.Type, order.Side, order.Quantity, order.Crypto)
+			pretext, kind = "Paper order rejected", response.Error
+		}
+		reply := response.New(text, pretext, kind, nil, "")
+		_, _, err := api.PostMessage(order.Channel, render.MsgOptions(reply)...)
+		if err != nil {
+			// The order is already filled or rejected, the other ones must still be announced
+			log.Println("Error announcing the paper order", order.ID, "of", order.User, err)
+		}
+	}
+	return nil
+}
+
+// describePaperFill Tells who filled what and at which price
+func describePaperFill(order *utils.PaperOrder) string {
+	return fmt.Sprintf("%s, your order #%d (%s %s %s %s) was filled at %s USD, %s USD in total",
+		order.User, order.ID, order.Type, order.Side, order.Quantity, order.Crypto, order.FillPrice, order.Quantity.Mul(order.FillPrice).StringFixed(2))
+}
+
+// cachedPrice Gets the current price of the crypto once per command or rules loop tick
+func cachedPrice(prices map[string]decimal.Decimal, crypto string) (decimal.Decimal, error) {
+	if price, found := prices[crypto]; found {
+		return price, nil
+	}
+	price, err := getCurrentPrice(crypto)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	prices[crypto] = price
+	return price, nil
+}
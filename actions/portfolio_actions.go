@@ -0,0 +1,285 @@
+package actions
+
+import (
+	"bytes"
+	"crypto-bot/commands"
+	"crypto-bot/decimal"
+	"crypto-bot/downsample"
+	"crypto-bot/lots"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"encoding/csv"
+	"fmt"
+	"github.com/slack-go/slack"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HandleTrade Records a buy or a sell (side) of the user: buy 0.5 btc [at 30000]. Without a price, the current one is
+// used. The command registry already checked the crypto and that the quantity and the price are numbers
+func HandleTrade(args commands.Values, userName string, side string, fields []response.Field) *response.Response {
+	quantity, err := decimal.NewFromString(args.Arg("quantity"))
+	if err != nil || quantity.Sign() <= 0 {
+		return response.New("That´s not a valid quantity! It must be a possitive number", "Try again!", response.Highlight, fields, "")
+	}
+	abbreviatedCryptoName, _ := utils.GetAbbreviatedCryptoName(args.Arg("crypto"))
+
+	var price decimal.Decimal
+	if value, found := args.Option("at"); found {
+		price, err = decimal.NewFromString(value)
+		if err != nil || price.Sign() <= 0 {
+			return response.New("That´s not a valid price! It must be a possitive number", "Try again!", response.Highlight, fields, "")
+		}
+	} else {
+		price, err = getCurrentPrice(abbreviatedCryptoName)
+		if err != nil {
+			return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+		}
+	}
+
+	err = utils.SaveTransaction(utils.Transaction{
+		User:     userName,
+		Date:     time.Now(),
+		Side:     side,
+		Crypto:   abbreviatedCryptoName,
+		Quantity: quantity,
+		Price:    price,
+	})
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+
+	verb := "bought"
+	if side == utils.Sell {
+		verb = "sold"
+	}
+	text := fmt.Sprintf("You %s %s %s at %s USD (%s USD in total)", verb, quantity, abbreviatedCryptoName, price, quantity.Mul(price).StringFixed(2))
+	return response.New(text, "Noted!", response.Highlight, fields, "")
+}
+
+// HandlePortfolio Shows the holdings of the user with their cost basis, current value and P&L.
+// portfolio chart range plots its value over time, and portfolio allocation [pie|bar] the weight of each crypto
+func HandlePortfolio(args commands.Values, userName string, loc *time.Location, fields []response.Field) *response.Response {
+	transactions, err := utils.LoadTransactions(userName)
+	if err != nil {
+		return response.New("I couldn't read your transactions, please try again", "I'm Sorry", response.Error, fields, "")
+	}
+	if len(transactions) == 0 {
+		return response.New("You don't have any transactions yet, try: buy 0.5 btc at 30000", "Your portfolio is empty", response.Muted, fields, "")
+	}
+	holdings, err := utils.ComputeHoldings(transactions)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+
+	// The command registry already checked the subcommand
+	switch args.Subcommand {
+	case "chart":
+		return handlePortfolioChart(args.List("range"), transactions, loc, fields)
+	case "allocation":
+		return handlePortfolioAllocation(args.Arg("style"), holdings, fields)
+	}
+
+	text, err := describeHoldings(holdings)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	return response.New(text, "Here is your portfolio", response.Info, fields, "")
+}
+
+// describeHoldings Writes each open holding with its cost, value and unrealized P&L, and the totals
+func describeHoldings(holdings []*utils.Holding) (string, error) {
+	var sb strings.Builder
+	totalCost, totalValue, totalRealized := decimal.Zero, decimal.Zero, decimal.Zero
+	for _, holding := range holdings {
+		totalRealized = totalRealized.Add(holding.RealizedPnL)
+		if holding.Quantity.IsZero() {
+			continue
+		}
+		price, err := getCurrentPrice(holding.Crypto)
+		if err != nil {
+			return "", err
+		}
+		value := holding.Quantity.Mul(price)
+		unrealized := value.Sub(holding.CostBasis)
+		totalCost = totalCost.Add(holding.CostBasis)
+		totalValue = totalValue.Add(value)
+		sb.WriteString(fmt.Sprintf("%s %s: cost %s USD (avg %s) | value %s USD | unrealized %s\n",
+			holding.Quantity, holding.Crypto, holding.CostBasis.StringFixed(2), holding.AverageCost().StringFixed(2),
+			value.StringFixed(2), formatPnL(unrealized, holding.CostBasis)))
+	}
+	sb.WriteString(fmt.Sprintf("Total: cost %s USD | value %s USD | unrealized %s | realized %s",
+		totalCost.StringFixed(2), totalValue.StringFixed(2), formatPnL(totalValue.Sub(totalCost), totalCost), formatPnL(totalRealized, decimal.Zero)))
+
+	return sb.String(), nil
+}
+
+// handlePortfolioChart Plots the value of the portfolio and its cost basis within the range (since the first transaction by default)
+func handlePortfolioChart(rangeArgs []string, transactions []utils.Transaction, loc *time.Location, fields []response.Field) *response.Response {
+	var timeRange utils.TimeRange
+	var err error
+	if len(rangeArgs) == 0 {
+		timeRange = utils.TimeRange{From: transactions[0].Date, To: time.Now()}
+	} else {
+		timeRange, err = utils.ParseTimeRange(rangeArgs, time.Now(), loc)
+		if err != nil {
+			return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+		}
+	}
+
+	var cryptos, fullCryptoNames []string
+	seen := make(map[string]bool)
+	for _, transaction := range transactions {
+		if seen[transaction.Crypto] {
+			continue
+		}
+		seen[transaction.Crypto] = true
+		fullCryptoName, _ := utils.GetFullCryptoName(transaction.Crypto)
+		cryptos = append(cryptos, transaction.Crypto)
+		fullCryptoNames = append(fullCryptoNames, fullCryptoName)
+	}
+
+	series, err := getPriceSeries(fullCryptoNames, timeRange.From, timeRange.To)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	timestamps, prices := utils.AlignSeries(series)
+	values, costs, err := utils.PortfolioValueSeries(transactions, cryptos, timestamps, prices)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+
+	config := utils.BuildComparisonChart(timestamps, [][]float64{values, costs}, []string{"Portfolio value", "Cost basis"}, "USD", loc, downsample.Default)
+	chart, err := renderChart(config)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	return response.New("Here is the value of your portfolio over time", "As you wanted", response.Info, fields, chart)
+}
+
+// handlePortfolioAllocation Charts the current weight of each crypto in the portfolio, as a pie or a bar chart
+func handlePortfolioAllocation(chartType string, holdings []*utils.Holding, fields []response.Field) *response.Response {
+	if chartType == "" {
+		chartType = "pie"
+	}
+
+	var labels []string
+	var values []float64
+	var sb strings.Builder
+	total := decimal.Zero
+	holdingValues := make([]decimal.Decimal, 0, len(holdings))
+	for _, holding := range holdings {
+		if holding.Quantity.IsZero() {
+			continue
+		}
+		price, err := getCurrentPrice(holding.Crypto)
+		if err != nil {
+			return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+		}
+		value := holding.Quantity.Mul(price)
+		total = total.Add(value)
+		holdingValues = append(holdingValues, value)
+		labels = append(labels, holding.Crypto)
+		values = append(values, value.Float64())
+	}
+	if total.IsZero() {
+		return response.New("You don't hold any crypto right now", "Your portfolio is empty", response.Muted, fields, "")
+	}
+	for i, value := range holdingValues {
+		sb.WriteString(fmt.Sprintf("%s: %s USD (%s%%)\n", labels[i], value.StringFixed(2), value.Div(total).Mul(decimal.New(100)).StringFixed(2)))
+	}
+
+	chart, err := renderChart(utils.BuildAllocationChart(labels, values, chartType))
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	return response.New(sb.String(), "Here is your allocation", response.Info, fields, chart)
+}
+
+// HandleGains Uploads a CSV with the realized gain of every lot disposed within the year: gains 2022 [fifo|lifo|average]
+func HandleGains(api *slack.Client, channel string, thread string, args commands.Values, userName string, loc *time.Location, fields []response.Field) *response.Response {
+	year, _ := strconv.Atoi(args.Arg("year"))
+	if year < 2009 || year > time.Now().Year() {
+		return response.New(fmt.Sprintf("%s is not a valid year", args.Arg("year")), "Command error", response.Error, fields, "")
+	}
+	method := lots.FIFO
+	if value := args.Arg("method"); value != "" {
+		var found bool
+		method, found = lots.ParseMethod(value)
+		if !found {
+			return response.New("The method must be fifo, lifo or average", "Command error", response.Error, fields, "")
+		}
+	}
+
+	transactions, err := utils.LoadTransactions(userName)
+	if err != nil {
+		return response.New("I couldn't read your transactions, please try again", "I'm Sorry", response.Error, fields, "")
+	}
+	result, err := lots.Compute(transactions, method)
+	if err != nil {
+		return response.New(err.Error(), "I'm Sorry", response.Error, fields, "")
+	}
+	disposals := result.Disposed(year, loc)
+	if len(disposals) == 0 {
+		return response.New(fmt.Sprintf("You didn't sell anything in %d", year), "No gains to report", response.Muted, fields, "")
+	}
+
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write([]string{"crypto", "quantity", "acquisition_date", "disposal_date", "proceeds_usd", "cost_usd", "gain_usd"})
+	proceeds, cost := decimal.Zero, decimal.Zero
+	for _, disposal := range disposals {
+		proceeds = proceeds.Add(disposal.Proceeds)
+		cost = cost.Add(disposal.Cost)
+		writer.Write([]string{
+			disposal.Crypto,
+			disposal.Quantity.String(),
+			disposal.Acquired.In(loc).Format(time.RFC3339),
+			disposal.Disposed.In(loc).Format(time.RFC3339),
+			disposal.Proceeds.StringFixed(2),
+			disposal.Cost.StringFixed(2),
+			disposal.Gain().StringFixed(2),
+		})
+	}
+	writer.Flush()
+	if writer.Error() != nil {
+		return response.New("unexpected error, please try again (Gains)", "I'm Sorry", response.Error, fields, "")
+	}
+
+	err = uploadFile(api, channel, thread, fmt.Sprintf("gains_%d_%s.csv", year, method), "csv", buffer.Bytes())
+	if err != nil {
+		return response.New("I couldn't upload the file, please try again", "I'm Sorry", response.Error, fields, "")
+	}
+	text := fmt.Sprintf("%d disposals in %d (%s): proceeds %s USD, cost %s USD, realized gain %s",
+		len(disposals), year, strings.ToUpper(string(method)), proceeds.StringFixed(2), cost.StringFixed(2), formatPnL(proceeds.Sub(cost), decimal.Zero))
+	return response.New(text, "Here is your gains report", response.Info, fields, "")
+}
+
+// getCurrentPrice Gets the current USD price of the crypto from the price provider
+func getCurrentPrice(abbreviatedCryptoName string) (decimal.Decimal, error) {
+	value, err := utils.GetCryptoValue(abbreviatedCryptoName, "USD")
+	if err != nil {
+		log.Println("Error getting the price of", abbreviatedCryptoName, err)
+		return decimal.Zero, fmt.Errorf("I couldn't get the price of %s, please try again", abbreviatedCryptoName)
+	}
+	price, err := decimal.NewFromString(value)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("I couldn't get the price of %s, please try again", abbreviatedCryptoName)
+	}
+	return price, nil
+}
+
+// formatPnL Writes a profit or loss with its sign, and its % over base when base isn't zero
+func formatPnL(pnl decimal.Decimal, base decimal.Decimal) string {
+	sign := ""
+	if pnl.Sign() > 0 {
+		sign = "+"
+	}
+	text := sign + pnl.StringFixed(2) + " USD"
+	if !base.IsZero() {
+		text += fmt.Sprintf(" (%s%s%%)", sign, pnl.Div(base).Mul(decimal.New(100)).StringFixed(2))
+	}
+	return text
+}
@@ -1,147 +1,176 @@
 package actions
 
 import (
-	"bufio"
+	"crypto-bot/commands"
+	"crypto-bot/render"
+	"crypto-bot/response"
 	"crypto-bot/utils"
 	"fmt"
 	"github.com/slack-go/slack"
-	"io"
+	"log"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
-// HandleSetLimit Sets the limit to then tell the user when the crypto value is higher
-func HandleSetLimit(splitedText []string, userName string, mode string, fields []slack.AttachmentField) slack.Attachment {
-	var text, pretext, color string
-	date := utils.GetFormattedActualDate()
-	if len(splitedText) < 4 {
-		text = fmt.Sprintf("Please try again")
-		pretext = "Command error"
-		color = "#ff0000"
+// HandleSetLimit Sets the limit to then tell the user, by direct message, when the crypto value is higher
+func HandleSetLimit(api *slack.Client, args commands.Values, userID string, userName string, mode string, fields []response.Field) *response.Response {
+	var text, pretext string
+	var kind response.Kind
+	var buttons []response.Button
+	// Rules are saved in UTC, and shown in the timezone of who reads them
+	date := time.Now().UTC().Format(time.RFC3339Nano)
+	// The command registry already checked there is a crypto and a number
+	crypto := strings.ToUpper(args.Arg("crypto"))
+	price, _ := strconv.ParseFloat(args.Arg("value"), 64)
+	if price <= 0 {
+		text = "That´s not a valid value! It must be a possitive number"
+		pretext = "Try again!"
+		kind = response.Highlight
 	} else {
-		crypto := strings.ToUpper(splitedText[2])
-		price, err := strconv.ParseFloat(splitedText[3], 64)
+		// Posting to a user ID sends the alert to the user's DM with the bot
+		err := setBarrierPrice(userName, userID, date, crypto, price, mode, userID)
 		if err == nil {
-			if price <= 0 {
-				text = "That´s not a valid value! It must be a possitive number"
-				pretext = "Try again!"
-				color = "#ff8000"
-			} else {
-				err := setBarrierPrice(userName, date, crypto, price, mode)
-				if err == nil {
-					text = "I'll let you know when that happens"
-					pretext = "Good work!"
-					color = "#ff8000"
-				} else {
-					text = err.Error()
-					pretext = "I'm Sorry"
-					color = "#ff0000"
-				}
-			}
+			text = "I'll let you know by direct message when that happens"
+			pretext = "Good work!"
+			kind = response.Highlight
+			buttons = append(buttons, response.Button{Text: "Edit", ActionID: EditAlertAction, Value: date})
+			// Publishing the Home tab is slow, it mustn't delay the reply
+			go RefreshHome(api, userName)
 		} else {
 			text = err.Error()
 			pretext = "I'm Sorry"
-			color = "#ff0000"
+			kind = response.Error
 		}
 	}
 
-	return utils.GetAttachment(text, pretext, color, fields, "")
+	reply := response.New(text, pretext, kind, fields, "")
+	reply.Buttons = buttons
+	return reply
 }
 
-func VerifyRules(fileName string, api *slack.Client) error {
+// HandleAlert Offers the alert form. Forms can only be opened from a button or a slash command, not from a mention
+func HandleAlert(args commands.Values, fields []response.Field) *response.Response {
+	crypto := args.Arg("crypto")
+	reply := response.New("Set the crypto, the value, when it expires and where I tell you", "Let's set an alert", response.Info, fields, "")
+	return reply.AddButton(response.Button{Text: "Set alert", ActionID: SetAlertAction, Value: crypto, Primary: true})
+}
 
-	currentCryptoPrices := make(map[string]float64)
-	inFile, err := os.Open(fileName)
-	if err != nil {
-		return nil
-	}
-	defer inFile.Close()
-	outFile, err := os.OpenFile(fileName, os.O_RDWR, 0777)
+// VerifyRules Announces the active rules whose crypto went past their value, and closes them and the expired ones.
+// Rules that can't be announced or closed are logged, so they don't stop the rest
+func VerifyRules(fileName string, api *slack.Client) error {
+	rules, err := utils.LoadActiveRules(fileName)
 	if err != nil {
 		return err
 	}
-	defer outFile.Close()
-	reader := bufio.NewReaderSize(inFile, 10*1024)
-	for {
-		status, err := reader.ReadString('|')
-		if err != nil {
-			if err != io.EOF {
-				fmt.Println("error:", err)
-			}
-			break
-		}
-
-		switch status {
-		case "Active|":
-			data, err := reader.ReadString('\n')
-			if err != nil {
-				if err != io.EOF {
-					fmt.Println("error:", err)
-				}
-				break
+	currentCryptoPrices := make(map[string]float64)
+	// failedPrices Cryptos whose price couldn't be fetched, their rules are checked again on the next tick
+	failedPrices := make(map[string]bool)
+	now := time.Now()
+	// changed Users whose rules were closed, their Home tab is refreshed
+	changed := make(map[string]bool)
+	for _, reg := range rules {
+		status := utils.ExpiredRule
+		if !reg.Expired(now) {
+			if failedPrices[reg.Crypto()] {
+				continue
 			}
-			reg, err := utils.LoadData(data)
+			past, err := utils.IsPricePastBarrier(*reg, currentCryptoPrices)
 			if err != nil {
-				return err
+				failedPrices[reg.Crypto()] = true
+				log.Println("Error getting the price of", reg.Crypto(), "for the rule", reg.ID(), "of", reg.User(), err)
+				continue
 			}
-			if utils.IsPricePastBarrier(*reg, currentCryptoPrices) {
-				err := utils.PostMessageRule(reg, api)
-				if err != nil {
-					return err
-				}
-				outFile.WriteString("Closed|")
-			} else {
-				outFile.WriteString("Active|")
+			if !past {
+				continue
 			}
-			outFile.WriteString(data)
-
-		case "Closed|":
-			fmt.Print("\nImprimo: ", status, "\n")
-			data, err := reader.ReadString('\n')
+			status = utils.FiredRule
+			err = postRuleMessage(api, reg)
 			if err != nil {
-				if err != io.EOF {
-					fmt.Println("error:", err)
-				}
-				break
+				// Closed anyway, or it would be posted again on every check
+				log.Println("Error announcing the rule", reg.ID(), "of", reg.User(), err)
+				status = utils.UndeliverableRule
 			}
-			outFile.WriteString("Closed|" + data)
 		}
+		err := utils.CloseRule(reg.User(), reg.ID(), status, now)
+		if err != nil {
+			log.Println("Error closing the rule", reg.ID(), "of", reg.User(), err)
+			continue
+		}
+		changed[reg.User()] = true
+	}
+	// Publishing the Home tabs is slow, it mustn't delay the next check
+	if len(changed) > 0 {
+		go func() {
+			for userName := range changed {
+				RefreshHome(api, userName)
+			}
+		}()
 	}
 	return nil
 }
 
-func setBarrierPrice(name string, date string, crypto string, price float64, barrierType string) error {
-	abbreviatedCryptoName, found := utils.GetAbbreviatedCryptoName(crypto)
-	if !found {
-		return fmt.Errorf("I don't support that Crypto ID or it doesn't exist (yet)")
+// postRuleMessage Announces that the crypto of the rule went past its value, to the channel of the rule or the bot one
+func postRuleMessage(api *slack.Client, rule *utils.Rule) error {
+	loc := utils.UserLocation(rule.User(), profileTimezone(api, rule))
+	fields := []response.Field{
+		{
+			Title: "Date",
+			Value: utils.FormatRuleDate(rule.ID(), loc),
+		}, {
+			Title: "Initializer",
+			Value: rule.User(),
+		},
 	}
 
-	str := ""
-	if barrierType == "lowLimit" {
-		str = "Active|" + name + "|" + date + "|" + abbreviatedCryptoName + "|" + fmt.Sprintf("%f", price) + "|" + "lowLimit" + "\n"
-	} else {
-		str = "Active|" + name + "|" + date + "|" + abbreviatedCryptoName + "|" + fmt.Sprintf("%f", price) + "|" + "highLimit" + "\n"
+	text := fmt.Sprintf(rule.Rule().String()+": "+rule.Crypto()+" has reached the value %f at %s", rule.Price(), utils.GetFormattedActualDate(loc))
+	pretext := "As you requested!"
+
+	reply := response.New(text, pretext, response.Success, fields, "")
+
+	channel := os.Getenv("SLACK_CHANNEL_ID")
+	if rule.Channel() != "" {
+		channel = rule.Channel()
 	}
-	b := []byte(str)
-	err := saveRule(b)
+	_, _, err := api.PostMessage(channel, render.MsgOptions(reply)...)
+	return err
+}
+
+// profileTimezone Timezone of the Slack profile of the user of the rule, empty if it can't be found
+func profileTimezone(api *slack.Client, rule *utils.Rule) string {
+	userID := ruleUserID(rule)
+	if userID == "" {
+		return ""
+	}
+	user, err := api.GetUserInfo(userID)
 	if err != nil {
-		return fmt.Errorf("Please try again")
+		log.Println("Error getting the user to find the timezone of", rule.User(), err)
+		return ""
 	}
-	return nil
+	return user.TZ
 }
 
-func saveRule(b []byte) error {
-	rulesFile, err := os.OpenFile("alarms.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	defer rulesFile.Close()
-	if err != nil {
-		fmt.Print(err.Error())
-		os.Exit(1)
+// ruleUserID Slack ID of the user of the rule. Rules saved before the ID was kept are looked up among the Home tab
+// users, empty if the user isn't one
+func ruleUserID(rule *utils.Rule) string {
+	if rule.UserID() != "" {
+		return rule.UserID()
 	}
-	_, err = rulesFile.Write(b)
+	userID, _ := utils.GetHomeUser(rule.User())
+	return userID
+}
+
+func setBarrierPrice(name string, userID string, date string, crypto string, price float64, barrierType string, channel string) error {
+	abbreviatedCryptoName, found := utils.GetAbbreviatedCryptoName(crypto)
+	if !found {
+		return fmt.Errorf("I don't support that Crypto ID or it doesn't exist (yet)")
+	}
+	rule, _ := utils.ParseRule(barrierType)
+
+	err := utils.SaveRule(utils.NewRule(name, userID, date, abbreviatedCryptoName, price, rule, "", channel))
 	if err != nil {
-		fmt.Print(err.Error())
-		os.Exit(1)
+		return fmt.Errorf("Please try again")
 	}
 	return nil
 }
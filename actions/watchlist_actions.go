@@ -0,0 +1,162 @@
+package actions
+
+import (
+	"crypto-bot/commands"
+	"crypto-bot/downsample"
+	"crypto-bot/render"
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"fmt"
+	"github.com/slack-go/slack"
+	"log"
+	"strings"
+	"time"
+)
+
+// sparklinePoints Characters of the sparkline drawn for each crypto in the digest
+const sparklinePoints = 24
+
+// HandleWatch Manages the watchlist of the user, or of the channel with watch channel ...:
+// watch [list], watch add btc eth, watch remove btc, watch digest 09:00|off
+func HandleWatch(args commands.Values, userID string, userName string, channel string, loc *time.Location, fields []response.Field) *response.Response {
+	subcommand := args.Subcommand
+	kind, ownerID, ownerName, timezone := utils.UserWatchlist, userID, userName, loc.String()
+	if subcommand == "channel" || strings.HasPrefix(subcommand, "channel ") {
+		kind, ownerID, ownerName, timezone = utils.ChannelWatchlist, channel, channel, utils.DefaultLocation().String()
+		subcommand = strings.TrimPrefix(strings.TrimPrefix(subcommand, "channel"), " ")
+	}
+
+	if subcommand == "" || subcommand == "list" {
+		watchlist, err := utils.GetWatchlist(kind, ownerID, ownerName)
+		if err != nil {
+			return response.New("I couldn't read the watchlist, please try again", "I'm Sorry", response.Error, fields, "")
+		}
+		return watchlistResponse(watchlist, fields)
+	}
+
+	// The command registry already checked the subcommand and its arguments
+	var digestErr error
+	watchlist, err := utils.UpdateWatchlist(kind, ownerID, ownerName, func(watchlist *utils.Watchlist) error {
+		switch subcommand {
+		case "add", "remove":
+			var cryptos []string
+			for _, arg := range args.List("cryptos") {
+				crypto, _ := utils.GetAbbreviatedCryptoName(arg)
+				cryptos = append(cryptos, crypto)
+			}
+			if subcommand == "add" {
+				watchlist.AddCryptos(cryptos)
+			} else {
+				watchlist.RemoveCryptos(cryptos)
+			}
+		case "digest":
+			watchlist.DigestTime = ""
+			if digestTime := args.Arg("time"); digestTime != "off" {
+				watchlist.DigestTime = digestTime
+			}
+			watchlist.Timezone = timezone
+			digestErr = watchlist.ScheduleNextRun(time.Now())
+			return digestErr
+		}
+		return nil
+	})
+	if digestErr != nil {
+		return response.New(digestErr.Error(), "Try again!", response.Highlight, fields, "")
+	}
+	if err != nil {
+		return response.New("I couldn't save the watchlist, please try again", "I'm Sorry", response.Error, fields, "")
+	}
+	return watchlistResponse(watchlist, fields)
+}
+
+// watchlistResponse Describes the watchlist and its digest
+func watchlistResponse(watchlist *utils.Watchlist, fields []response.Field) *response.Response {
+	owner := "Your"
+	if watchlist.Kind == utils.ChannelWatchlist {
+		owner = "This channel's"
+	}
+	if len(watchlist.Cryptos) == 0 {
+		return response.New(fmt.Sprintf("%s watchlist is empty, try watch add btc eth sol", owner), "Nothing to watch", response.Muted, fields, "")
+	}
+
+	text := fmt.Sprintf("%s watchlist: %s", owner, strings.Join(watchlist.Cryptos, ", "))
+	if watchlist.DigestTime == "" {
+		text += "\nThere is no daily digest, set one with watch digest 09:00"
+	} else {
+		text += fmt.Sprintf("\nDaily digest at %s (%s), next one on %s", watchlist.DigestTime, watchlist.Timezone, utils.FormatDate(watchlist.NextRun, watchlist.Location()))
+	}
+	return response.New(text, "Here you go", response.Info, fields, "")
+}
+
+// RunDigests Posts the digests that are due and schedules their next run. Digests missed while the bot was down are
+// posted once when it starts again, and digests that can't be posted are logged and skipped until the next day
+func RunDigests(api *slack.Client) error {
+	watchlists, err := utils.LoadWatchlists()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, watchlist := range watchlists {
+		if watchlist.DigestTime == "" || watchlist.NextRun.IsZero() || watchlist.NextRun.After(now) {
+			continue
+		}
+		if len(watchlist.Cryptos) > 0 {
+			err = postDigest(api, watchlist)
+			if err != nil {
+				// Scheduled for the next day anyway, or a digest to a channel the bot was removed from would be
+				// retried on every tick
+				log.Println("Error posting the digest of", watchlist.OwnerName, err)
+			}
+		}
+		// Posting is slow, the watchlist may have changed meanwhile
+		err = utils.ScheduleNextDigest(watchlist.Kind, watchlist.OwnerID, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// postDigest Posts the price, 24h change and sparkline of each crypto of the watchlist to its owner
+func postDigest(api *slack.Client, watchlist *utils.Watchlist) error {
+	fullCryptoNames := make([]string, len(watchlist.Cryptos))
+	for i, crypto := range watchlist.Cryptos {
+		fullCryptoName, found := utils.GetFullCryptoName(crypto)
+		if !found {
+			return fmt.Errorf("unknown crypto %s", crypto)
+		}
+		fullCryptoNames[i] = fullCryptoName
+	}
+	now := time.Now()
+	series, err := getPriceSeries(fullCryptoNames, now.Add(-24*time.Hour), now)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	for i, crypto := range watchlist.Cryptos {
+		prices := series[i]
+		first, last := prices[0][1], prices[len(prices)-1][1]
+		change := "n/a"
+		if first != 0 {
+			change = fmt.Sprintf("%+.2f%%", (last-first)/first*100)
+		}
+		sparkline := make([]float64, 0, sparklinePoints)
+		for _, point := range downsample.Series(prices, sparklinePoints, downsample.Default) {
+			sparkline = append(sparkline, point[1])
+		}
+		sb.WriteString(fmt.Sprintf("%s  %.2f USD  %s  %s\n", crypto, last, change, utils.Sparkline(sparkline)))
+	}
+
+	loc := watchlist.Location()
+	fields := []response.Field{
+		{
+			Title: "Date",
+			Value: utils.GetFormattedActualDate(loc),
+		},
+	}
+	reply := response.New(sb.String(), "Your daily digest", response.Info, fields, "")
+	// Posting to a user ID sends the digest to the user's DM with the bot
+	_, _, err = api.PostMessage(watchlist.OwnerID, render.MsgOptions(reply)...)
+	return err
+}
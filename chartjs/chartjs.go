@@ -0,0 +1,240 @@
+// Package chartjs models the Chart.js (v3) configuration sent to QuickChart, so charts are built
+// with Go values that always marshal to valid JSON instead of hand-written JavaScript
+package chartjs
+
+import (
+	"math"
+	"strconv"
+)
+
+// Version Chart.js version the config is written for
+const Version = "3"
+
+type Config struct {
+	Type    string  `json:"type"`
+	Data    Data    `json:"data"`
+	Options Options `json:"options"`
+}
+
+type Data struct {
+	Labels   []string   `json:"labels"`
+	Datasets []*Dataset `json:"datasets"`
+}
+
+type Dataset struct {
+	Type        string      `json:"type,omitempty"`
+	Label       string      `json:"label"`
+	Data        Values      `json:"data"`
+	Fill        interface{} `json:"fill"`
+	BorderColor string      `json:"borderColor,omitempty"`
+	// BackgroundColor A color, or a list with a color for each value (pie charts)
+	BackgroundColor interface{} `json:"backgroundColor,omitempty"`
+	BorderWidth     float64     `json:"borderWidth,omitempty"`
+	BorderDash      []float64   `json:"borderDash,omitempty"`
+	YAxisID         string      `json:"yAxisID,omitempty"`
+	// Order Datasets with a lower order are drawn on top
+	Order int `json:"order,omitempty"`
+}
+
+// Values Dataset values, NaN is sent as null so Chart.js leaves a gap
+type Values []float64
+
+func (values Values) MarshalJSON() ([]byte, error) {
+	b := []byte{'['}
+	for i, v := range values {
+		if i != 0 {
+			b = append(b, ',')
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			b = append(b, "null"...)
+		} else {
+			b = strconv.AppendFloat(b, v, 'f', -1, 64)
+		}
+	}
+	return append(b, ']'), nil
+}
+
+type Options struct {
+	Elements *Elements         `json:"elements,omitempty"`
+	Scales   map[string]*Scale `json:"scales,omitempty"`
+	Plugins  Plugins           `json:"plugins"`
+}
+
+type Elements struct {
+	Point PointElement `json:"point"`
+}
+
+type PointElement struct {
+	Radius float64 `json:"radius"`
+}
+
+type Scale struct {
+	Type        string  `json:"type,omitempty"`
+	Position    string  `json:"position,omitempty"`
+	Stack       string  `json:"stack,omitempty"`
+	StackWeight float64 `json:"stackWeight,omitempty"`
+	Offset      bool    `json:"offset,omitempty"`
+	// SuggestedMin and SuggestedMax Widen the range of the scale to include those values
+	SuggestedMin *float64    `json:"suggestedMin,omitempty"`
+	SuggestedMax *float64    `json:"suggestedMax,omitempty"`
+	Title        *ScaleTitle `json:"title,omitempty"`
+	Ticks        *Ticks      `json:"ticks,omitempty"`
+}
+
+type ScaleTitle struct {
+	Display bool   `json:"display"`
+	Text    string `json:"text"`
+}
+
+type Ticks struct {
+	MaxTicksLimit int `json:"maxTicksLimit,omitempty"`
+}
+
+type Plugins struct {
+	Legend     *Legend     `json:"legend,omitempty"`
+	Title      *Title      `json:"title,omitempty"`
+	Annotation *Annotation `json:"annotation,omitempty"`
+}
+
+type Legend struct {
+	Display  bool   `json:"display"`
+	Position string `json:"position,omitempty"`
+}
+
+type Title struct {
+	Display bool   `json:"display"`
+	Text    string `json:"text"`
+}
+
+// Annotation Options of chartjs-plugin-annotation, available in QuickChart
+type Annotation struct {
+	Annotations map[string]*AnnotationItem `json:"annotations"`
+}
+
+type AnnotationItem struct {
+	Type        string           `json:"type"`
+	ScaleID     string           `json:"scaleID,omitempty"`
+	Value       float64          `json:"value"`
+	BorderColor string           `json:"borderColor,omitempty"`
+	BorderWidth float64          `json:"borderWidth,omitempty"`
+	BorderDash  []float64        `json:"borderDash,omitempty"`
+	Label       *AnnotationLabel `json:"label,omitempty"`
+}
+
+type AnnotationLabel struct {
+	// Enabled is read by the plugin v1 and Display by v2, so both are set
+	Enabled         bool   `json:"enabled"`
+	Display         bool   `json:"display"`
+	Content         string `json:"content"`
+	Position        string `json:"position,omitempty"`
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+}
+
+// NewConfig Creates an empty chart of the given type (line, bar, pie...) with the legend shown
+func NewConfig(chartType string) *Config {
+	return &Config{
+		Type: chartType,
+		Data: Data{
+			Labels:   []string{},
+			Datasets: []*Dataset{},
+		},
+		Options: Options{
+			Plugins: Plugins{
+				Legend: &Legend{Display: true},
+			},
+		},
+	}
+}
+
+// NewLineChart Creates a line chart without dots on every point, as used for price series
+func NewLineChart() *Config {
+	config := NewConfig("line")
+	config.Options.Elements = &Elements{Point: PointElement{Radius: 0}}
+	return config
+}
+
+// NewLineDataset Creates a not filled line dataset
+func NewLineDataset(label string, data []float64, color string) *Dataset {
+	return &Dataset{
+		Type:            "line",
+		Label:           label,
+		Data:            data,
+		Fill:            false,
+		BorderColor:     color,
+		BackgroundColor: color,
+	}
+}
+
+// NewBarDataset Creates a bar dataset
+func NewBarDataset(label string, data []float64, color string) *Dataset {
+	return &Dataset{
+		Type:            "bar",
+		Label:           label,
+		Data:            data,
+		Fill:            false,
+		BackgroundColor: color,
+	}
+}
+
+// NewPieDataset Creates a pie dataset, with a color for each value
+func NewPieDataset(label string, data []float64, colors []string) *Dataset {
+	return &Dataset{
+		Label:           label,
+		Data:            data,
+		Fill:            false,
+		BackgroundColor: colors,
+	}
+}
+
+// AddDataset Appends the dataset to the chart
+func (config *Config) AddDataset(dataset *Dataset) {
+	config.Data.Datasets = append(config.Data.Datasets, dataset)
+}
+
+// SetScale Adds or replaces the scale with that id
+func (config *Config) SetScale(id string, scale *Scale) {
+	if config.Options.Scales == nil {
+		config.Options.Scales = make(map[string]*Scale)
+	}
+	config.Options.Scales[id] = scale
+}
+
+// AddAnnotation Adds or replaces the annotation with that id
+func (config *Config) AddAnnotation(id string, annotation *AnnotationItem) {
+	if config.Options.Plugins.Annotation == nil {
+		config.Options.Plugins.Annotation = &Annotation{Annotations: make(map[string]*AnnotationItem)}
+	}
+	config.Options.Plugins.Annotation.Annotations[id] = annotation
+}
+
+// Include Widens the scale so value is always visible
+func (scale *Scale) Include(value float64) {
+	if scale.SuggestedMin == nil || value < *scale.SuggestedMin {
+		scale.SuggestedMin = &value
+	}
+	if scale.SuggestedMax == nil || value > *scale.SuggestedMax {
+		scale.SuggestedMax = &value
+	}
+}
+
+// NewHorizontalLine Creates a horizontal line annotation at value on the scale, with an optional label
+func NewHorizontalLine(scaleID string, value float64, color string, label string) *AnnotationItem {
+	annotation := &AnnotationItem{
+		Type:        "line",
+		ScaleID:     scaleID,
+		Value:       value,
+		BorderColor: color,
+		BorderWidth: 1,
+		BorderDash:  []float64{6, 4},
+	}
+	if label != "" {
+		annotation.Label = &AnnotationLabel{
+			Enabled:         true,
+			Display:         true,
+			Content:         label,
+			Position:        "start",
+			BackgroundColor: color,
+		}
+	}
+	return annotation
+}
@@ -0,0 +1,433 @@
+// Package commands keeps the registry of the bot commands. Each command declares its arguments, so the arguments
+// are checked and the usage errors are written the same way for all of them before the handler runs
+package commands
+
+import (
+	"crypto-bot/response"
+	"crypto-bot/utils"
+	"fmt"
+	"github.com/slack-go/slack"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArgType What an argument must look like
+type ArgType int
+
+const (
+	// Text Anything
+	Text ArgType = iota
+	// Crypto A supported crypto, by its name or abbreviation
+	Crypto
+	// Cryptos One or more comma separated cryptos: btc,eth,sol
+	Cryptos
+	Number
+	Integer
+	// ID A whole number, optionally written as #3 like the numbers of the paper orders
+	ID
+	// Date DD-MM-YYYY, YYYY-MM-DD or an ISO datetime
+	Date
+	// Interval A live price duration or an export interval: 30s, 15m, 4h, 1d or 1w
+	Interval
+	// Choice One of the Choices of the argument
+	Choice
+)
+
+// Argument A positional argument of a command
+type Argument struct {
+	Name     string
+	Type     ArgType
+	Choices  []string
+	Optional bool
+	// Variadic Takes every argument left but the ones of the required arguments after it. Only one argument can be
+	// variadic
+	Variadic bool
+	// Usage How the argument is shown in the usage line, when its name isn't enough
+	Usage string
+}
+
+// Option An argument introduced by a keyword, like every 30s or limit 30000. Options go after the positional
+// arguments, in any order
+type Option struct {
+	// Keywords Words that introduce the option, only one of them can be given
+	Keywords []string
+	Arg      Argument
+}
+
+// Flag An option given as +name (or --name)
+type Flag struct {
+	Name        string
+	Description string
+}
+
+// Context Everything a handler needs to run a command
+type Context struct {
+	API      *slack.Client
+	User     *slack.User
+	UserName string
+	Channel  string
+	// UploadChannel Where files are uploaded, the bot can't upload to channels it hasn't joined
+	UploadChannel string
+	// Timestamp Of the message that mentioned the bot, empty for slash commands
+	Timestamp string
+	// MessageThread Thread the message was written in, empty when it isn't a reply
+	MessageThread string
+	// Message The message with the command, when the event includes it. App mentions don't include its files, so it
+	// is nil for them
+	Message *slack.Message
+	// Thread Where the reply goes, empty to post it in the channel
+	Thread   string
+	Location *time.Location
+	Fields   []response.Field
+	Args     []string
+	Flags    []string
+	// Values The arguments and options checked by Validate, by their names
+	Values
+}
+
+// Values The arguments of a command once validated, by the names they were declared with
+type Values struct {
+	// Subcommand The names of the subcommands picked by the arguments, like "channel add". Empty when there is none
+	Subcommand string
+	args       map[string][]string
+	options    map[string]string
+	flags      map[string]bool
+}
+
+// Arg The value of the argument, empty when it wasn't given. Variadic arguments are joined by spaces
+func (v Values) Arg(name string) string {
+	return strings.Join(v.args[name], " ")
+}
+
+// List The values of a variadic argument
+func (v Values) List(name string) []string {
+	return v.args[name]
+}
+
+// Option The value given after the keyword, if the option was given with it
+func (v Values) Option(keyword string) (string, bool) {
+	value, found := v.options[keyword]
+	return value, found
+}
+
+// Flag Tells whether the +flag was given
+func (v Values) Flag(name string) bool {
+	return v.flags[name]
+}
+
+// Handler Runs a command. Handlers that post their reply themselves, like live prices, return nil
+type Handler func(ctx *Context) *response.Response
+
+// Command A command of the bot and how it is called
+type Command struct {
+	Name    string
+	Aliases []string
+	// Category Groups the command in the help
+	Category string
+	Summary  string
+	Args     []Argument
+	Options  []Option
+	Flags    []Flag
+	// Subcommands Commands that follow the name, like paper buy. The first argument picks one and the rest are
+	// checked against its arguments. Without arguments the command runs on its own
+	Subcommands []*Command
+	Examples    []string
+	// Permissions Who can use the command and whose data it changes, anyone when empty
+	Permissions string
+	Handler     Handler
+
+	// parent The command of a subcommand
+	parent *Command
+}
+
+// UsageError A command called with the wrong arguments
+type UsageError struct {
+	Command *Command
+	Problem string
+}
+
+func (e *UsageError) Error() string {
+	text := fmt.Sprintf("%s\nUsage: %s", e.Problem, e.Command.Usage())
+	if len(e.Command.Examples) > 0 {
+		text += "\nFor example: " + e.Command.Examples[0]
+	}
+	return text
+}
+
+var registry = make(map[string]*Command)
+
+// Register Adds a command, its name and aliases must be unique
+func Register(command *Command) {
+	for _, name := range append([]string{command.Name}, command.Aliases...) {
+		if _, found := registry[name]; found {
+			panic(fmt.Sprintf("command %s registered twice", name))
+		}
+		registry[name] = command
+	}
+	setParent(command)
+}
+
+// setParent Links the subcommands to their command, so their usage starts with its name
+func setParent(command *Command) {
+	for _, subcommand := range command.Subcommands {
+		subcommand.parent = command
+		setParent(subcommand)
+	}
+}
+
+// Lookup Finds a command by its name or one of its aliases
+func Lookup(name string) (*Command, bool) {
+	command, found := registry[strings.ToLower(name)]
+	return command, found
+}
+
+// All Every registered command, sorted by name
+func All() []*Command {
+	var commands []*Command
+	for name, command := range registry {
+		if name == command.Name {
+			commands = append(commands, command)
+		}
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// Usage One line description of the arguments: price <crypto>, chart <cryptos> <range...> [+volume] ...
+func (c *Command) Usage() string {
+	parts := []string{c.FullName()}
+	if len(c.Subcommands) > 0 {
+		var names []string
+		for _, subcommand := range c.Subcommands {
+			names = append(names, subcommand.Name)
+		}
+		parts = append(parts, "["+strings.Join(names, "|")+"]")
+	}
+	for _, arg := range c.Args {
+		parts = append(parts, arg.usage())
+	}
+	for _, option := range c.Options {
+		arg := option.Arg
+		arg.Optional = false
+		parts = append(parts, "["+strings.Join(option.Keywords, "|")+" "+arg.usage()+"]")
+	}
+	for _, flag := range c.Flags {
+		parts = append(parts, "[+"+flag.Name+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// FullName The name of the command after the names of the commands it belongs to: paper buy
+func (c *Command) FullName() string {
+	if c.parent == nil {
+		return c.Name
+	}
+	return c.parent.FullName() + " " + c.Name
+}
+
+// usage How the argument is shown in the usage line
+func (a *Argument) usage() string {
+	if a.Usage != "" {
+		return a.Usage
+	}
+	name := a.Name
+	if a.Type == Choice && len(a.Choices) > 1 {
+		name = strings.Join(a.Choices, "|")
+	}
+	if a.Variadic {
+		name += "..."
+	}
+	if a.Optional {
+		return "[" + name + "]"
+	} else if a.Type == Choice && len(a.Choices) == 1 {
+		return name
+	}
+	return "<" + name + ">"
+}
+
+// Validate Checks the arguments and flags against the ones declared by the command, and returns them by name
+func (c *Command) Validate(args []string, flags []string) (Values, error) {
+	values := Values{args: make(map[string][]string), options: make(map[string]string), flags: make(map[string]bool)}
+	for _, flag := range flags {
+		if !c.hasFlag(flag) {
+			return Values{}, &UsageError{c, fmt.Sprintf("%s doesn't have a +%s option", c.Name, flag)}
+		}
+		values.flags[flag] = true
+	}
+	err := c.validateArgs(args, &values)
+	if err != nil {
+		return Values{}, err
+	}
+	return values, nil
+}
+
+// validateArgs Checks the arguments against the subcommand they pick, or the arguments and options of the command
+func (c *Command) validateArgs(args []string, values *Values) error {
+	if len(c.Subcommands) > 0 && len(args) > 0 {
+		for _, subcommand := range c.Subcommands {
+			if subcommand.Name == args[0] {
+				values.Subcommand = strings.TrimSpace(values.Subcommand + " " + subcommand.Name)
+				return subcommand.validateArgs(args[1:], values)
+			}
+		}
+		return &UsageError{c, fmt.Sprintf("%s doesn't have a %q subcommand", c.Name, args[0])}
+	}
+
+	positional, err := c.validateOptions(args, values)
+	if err != nil {
+		return err
+	}
+	i := 0
+	for n, arg := range c.Args {
+		if i >= len(positional) {
+			if !arg.Optional {
+				return &UsageError{c, fmt.Sprintf("%s is missing", arg.Name)}
+			}
+			return nil
+		}
+		given := positional[i : i+1]
+		if arg.Variadic {
+			end := len(positional) - requiredArgs(c.Args[n+1:])
+			if end < i {
+				end = i
+			}
+			given = positional[i:end]
+			if len(given) == 0 {
+				if !arg.Optional {
+					return &UsageError{c, fmt.Sprintf("%s is missing", arg.Name)}
+				}
+				continue
+			}
+		}
+		for _, value := range given {
+			err := arg.check(value)
+			if err != nil {
+				return &UsageError{c, err.Error()}
+			}
+		}
+		values.args[arg.Name] = given
+		i += len(given)
+	}
+	if i < len(positional) {
+		return &UsageError{c, fmt.Sprintf("I don't know what to do with %q", strings.Join(positional[i:], " "))}
+	}
+	return nil
+}
+
+// validateOptions Checks the options after the positional arguments, keeping them by the keyword they were given with.
+// It returns the positional arguments
+func (c *Command) validateOptions(args []string, values *Values) ([]string, error) {
+	first := len(args)
+	for i, value := range args {
+		if _, found := c.option(value); found {
+			first = i
+			break
+		}
+	}
+
+	given := make(map[int]bool)
+	for i := first; i < len(args); i += 2 {
+		n, found := c.option(args[i])
+		if !found {
+			return nil, &UsageError{c, fmt.Sprintf("I don't know what to do with %q", args[i])}
+		}
+		option := c.Options[n]
+		if given[n] {
+			return nil, &UsageError{c, fmt.Sprintf("%s can only be given once", strings.Join(option.Keywords, " or "))}
+		}
+		given[n] = true
+		if i+1 >= len(args) {
+			return nil, &UsageError{c, fmt.Sprintf("%s is missing after %s", option.Arg.Name, args[i])}
+		}
+		err := option.Arg.check(args[i+1])
+		if err != nil {
+			return nil, &UsageError{c, err.Error()}
+		}
+		values.options[args[i]] = args[i+1]
+	}
+	return args[:first], nil
+}
+
+// option The index of the option introduced by the keyword
+func (c *Command) option(keyword string) (int, bool) {
+	for i, option := range c.Options {
+		for _, candidate := range option.Keywords {
+			if candidate == keyword {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// requiredArgs How many values the arguments need at least
+func requiredArgs(args []Argument) int {
+	required := 0
+	for _, arg := range args {
+		if !arg.Optional {
+			required++
+		}
+	}
+	return required
+}
+
+func (c *Command) hasFlag(name string) bool {
+	for _, flag := range c.Flags {
+		if flag.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// check Tells what is wrong with the value of the argument, if anything
+func (a *Argument) check(value string) error {
+	switch a.Type {
+	case Crypto:
+		if _, found := utils.GetAbbreviatedCryptoName(value); !found {
+			return fmt.Errorf("I don't support %s or it doesn't exist (yet)", value)
+		}
+	case Cryptos:
+		for _, crypto := range strings.Split(value, ",") {
+			if _, found := utils.GetAbbreviatedCryptoName(crypto); !found {
+				return fmt.Errorf("I don't support %s or it doesn't exist (yet)", crypto)
+			}
+		}
+	case Number:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return fmt.Errorf("%s must be a number, not %q", a.Name, value)
+		}
+	case Integer:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s must be a whole number, not %q", a.Name, value)
+		}
+	case ID:
+		if _, err := strconv.Atoi(strings.TrimPrefix(value, "#")); err != nil {
+			return fmt.Errorf("%s must be a number like 3 or #3, not %q", a.Name, value)
+		}
+	case Date:
+		if _, _, err := utils.ParseDate(value, time.UTC); err != nil {
+			return err
+		}
+	case Interval:
+		if _, err := utils.ParseInterval(value); err != nil {
+			return err
+		}
+	case Choice:
+		for _, choice := range a.Choices {
+			if value == choice {
+				return nil
+			}
+		}
+		if len(a.Choices) == 1 {
+			return fmt.Errorf("expected %s, not %q", a.Choices[0], value)
+		}
+		last := len(a.Choices) - 1
+		return fmt.Errorf("%s must be %s or %s, not %q", a.Name, strings.Join(a.Choices[:last], ", "), a.Choices[last], value)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package commands
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var export = &Command{
+	Name: "export",
+	Args: []Argument{
+		{Name: "coin", Type: Text},
+		{Name: "range", Type: Text, Variadic: true},
+		{Name: "format", Type: Choice, Choices: []string{"csv", "json"}},
+	},
+	Options: []Option{
+		{Keywords: []string{"every"}, Arg: Argument{Name: "interval", Type: Interval}},
+		{Keywords: []string{"limit", "stop"}, Arg: Argument{Name: "price", Type: Number}},
+	},
+	Flags:    []Flag{{Name: "chart"}},
+	Examples: []string{"export btc 30d csv"},
+}
+
+var whatif = &Command{
+	Name: "whatif",
+	Args: []Argument{
+		{Name: "amount", Type: Integer},
+		{Name: "on", Type: Choice, Choices: []string{"on"}},
+		{Name: "date", Type: Date},
+		{Name: "method", Type: Choice, Choices: []string{"fifo", "lifo", "average"}, Optional: true},
+	},
+}
+
+var paper = &Command{
+	Name: "paper",
+	Subcommands: []*Command{
+		{Name: "cancel", Args: []Argument{{Name: "order", Type: ID}}},
+		{Name: "channel", Subcommands: []*Command{
+			{Name: "add", Args: []Argument{{Name: "coins", Type: Text, Variadic: true}}},
+		}},
+	},
+}
+
+func init() {
+	setParent(paper)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		command    *Command
+		args       string
+		flags      []string
+		values     map[string][]string
+		options    map[string]string
+		subcommand string
+	}{
+		{command: export, args: "btc 30d csv", values: map[string][]string{"coin": {"btc"}, "range": {"30d"}, "format": {"csv"}}},
+		{
+			command: export,
+			args:    "btc 01-03-2022 05-03-2022 json every 1d stop 30000",
+			flags:   []string{"chart"},
+			values:  map[string][]string{"coin": {"btc"}, "range": {"01-03-2022", "05-03-2022"}, "format": {"json"}},
+			options: map[string]string{"every": "1d", "stop": "30000"},
+		},
+		{command: whatif, args: "1000 on 01-06-2020", values: map[string][]string{"amount": {"1000"}, "on": {"on"}, "date": {"01-06-2020"}}},
+		{command: whatif, args: "1000 on 2020-06-01 lifo", values: map[string][]string{"amount": {"1000"}, "on": {"on"}, "date": {"2020-06-01"}, "method": {"lifo"}}},
+		{command: paper, args: ""},
+		{command: paper, args: "cancel #3", values: map[string][]string{"order": {"#3"}}, subcommand: "cancel"},
+		{command: paper, args: "channel add btc eth", values: map[string][]string{"coins": {"btc", "eth"}}, subcommand: "channel add"},
+		{command: paper, args: "channel", subcommand: "channel"},
+	}
+	for _, test := range tests {
+		values, err := test.command.Validate(strings.Fields(test.args), test.flags)
+		if err != nil {
+			t.Errorf("%s %s failed: %v", test.command.Name, test.args, err)
+			continue
+		}
+		if values.Subcommand != test.subcommand {
+			t.Errorf("%s %s picked the subcommand %q, want %q", test.command.Name, test.args, values.Subcommand, test.subcommand)
+		}
+		for name, want := range test.values {
+			if got := values.List(name); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s %s: %s = %q, want %q", test.command.Name, test.args, name, got, want)
+			}
+		}
+		for keyword, want := range test.options {
+			if got, found := values.Option(keyword); !found || got != want {
+				t.Errorf("%s %s: option %s = %q, %v, want %q", test.command.Name, test.args, keyword, got, found, want)
+			}
+		}
+		for _, flag := range test.flags {
+			if !values.Flag(flag) {
+				t.Errorf("%s %s: +%s wasn't given", test.command.Name, test.args, flag)
+			}
+		}
+	}
+
+	values, _ := export.Validate(strings.Fields("btc 1 y csv"), nil)
+	if values.Arg("range") != "1 y" || values.Arg("missing") != "" || values.Flag("chart") {
+		t.Errorf("range = %q, missing = %q, +chart = %v", values.Arg("range"), values.Arg("missing"), values.Flag("chart"))
+	}
+	if _, found := values.Option("every"); found {
+		t.Error("every wasn't given")
+	}
+}
+
+func TestValidateErrors(t *testing.T) {
+	tests := []struct {
+		command *Command
+		args    string
+		flags   []string
+		problem string
+	}{
+		{command: export, args: "", problem: "coin is missing"},
+		{command: export, args: "btc", problem: "range is missing"},
+		// The variadic range leaves the last argument to the format
+		{command: export, args: "btc csv", problem: "range is missing"},
+		{command: export, args: "btc 1 y", problem: `format must be csv or json, not "y"`},
+		{command: export, args: "btc 30d", problem: "range is missing"},
+		{command: export, args: "btc 30d xml", problem: `format must be csv or json, not "xml"`},
+		{command: export, args: "btc 30d csv every 1d every 2d", problem: "every can only be given once"},
+		{command: export, args: "btc 30d csv limit 1 stop 2", problem: "limit or stop can only be given once"},
+		{command: export, args: "btc 30d csv every", problem: "interval is missing after every"},
+		{command: export, args: "btc 30d csv every 1y", problem: `"1y" is not a valid duration, try 30s, 15m, 4h, 1d or 1w`},
+		{command: export, args: "btc 30d csv limit lots", problem: `price must be a number, not "lots"`},
+		{command: export, args: "btc 30d csv every 1d extra", problem: `I don't know what to do with "extra"`},
+		{command: export, args: "btc 30d csv", flags: []string{"volume"}, problem: "export doesn't have a +volume option"},
+		{command: whatif, args: "1000 at 01-06-2020", problem: `expected on, not "at"`},
+		{command: whatif, args: "1000.5 on 01-06-2020", problem: `amount must be a whole number, not "1000.5"`},
+		{command: whatif, args: "1000 on 01-06-2020 hifo", problem: `method must be fifo, lifo or average, not "hifo"`},
+		{command: whatif, args: "1000 on 31-02-2020", problem: `"31-02-2020" is not a valid date: day out of range`},
+		{command: whatif, args: "1000 on 01-06-2020 lifo now", problem: `I don't know what to do with "now"`},
+		{command: paper, args: "sel 1 btc", problem: `paper doesn't have a "sel" subcommand`},
+		{command: paper, args: "channel remove btc", problem: `channel doesn't have a "remove" subcommand`},
+		{command: paper, args: "cancel three", problem: `order must be a number like 3 or #3, not "three"`},
+		{command: paper, args: "cancel", problem: "order is missing"},
+		{command: paper, args: "channel add", problem: "coins is missing"},
+	}
+	for _, test := range tests {
+		_, err := test.command.Validate(strings.Fields(test.args), test.flags)
+		var usageError *UsageError
+		if !errors.As(err, &usageError) {
+			t.Errorf("%s %s: error = %v, want a usage error", test.command.Name, test.args, err)
+			continue
+		}
+		if usageError.Problem != test.problem {
+			t.Errorf("%s %s: problem = %q, want %q", test.command.Name, test.args, usageError.Problem, test.problem)
+		}
+	}
+}
+
+func TestUsageError(t *testing.T) {
+	_, err := export.Validate([]string{"btc"}, nil)
+	want := "range is missing\n" +
+		"Usage: export <coin> <range...> <csv|json> [every <interval>] [limit|stop <price>] [+chart]\n" +
+		"For example: export btc 30d csv"
+	if err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+
+	// The usage of a subcommand starts with the name of its command, and it has no examples
+	_, err = paper.Validate([]string{"channel", "add"}, nil)
+	want = "coins is missing\nUsage: paper channel add <coins...>"
+	if err == nil || err.Error() != want {
+		t.Errorf("error = %v, want %q", err, want)
+	}
+}
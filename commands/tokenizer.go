@@ -0,0 +1,99 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// closingQuotes Quotes that can wrap an argument, Slack clients may turn the straight ones into curly ones
+var closingQuotes = map[rune]rune{
+	'"':  '"',
+	'\'': '\'',
+	'“':  '”',
+	'‘':  '’',
+}
+
+// Tokenize Splits the text on any amount of whitespace. Quoted arguments ("two words") are kept together, and so are
+// Slack references like <@U123> or <https://example.com|example>
+func Tokenize(text string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	inToken := false
+	var closing rune
+
+	for _, r := range text {
+		switch {
+		case closing != 0:
+			if r == closing {
+				closing = 0
+				if r != '>' {
+					continue
+				}
+			}
+			token.WriteRune(r)
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		case !inToken && closingQuotes[r] != 0:
+			closing = closingQuotes[r]
+			inToken = true
+		case !inToken && r == '<':
+			// Slack references keep their brackets
+			closing = '>'
+			inToken = true
+			token.WriteRune(r)
+		default:
+			token.WriteRune(r)
+			inToken = true
+		}
+	}
+	if closing != 0 && closing != '>' {
+		return nil, fmt.Errorf("there is a %c without its closing %c", firstOpening(closing), closing)
+	}
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
+
+// Parse Finds the command name after the mention (or the slash command) and separates its arguments from its +flags
+func Parse(tokens []string, mention string) (string, []string, []string) {
+	for i, token := range tokens {
+		if strings.EqualFold(token, mention) {
+			tokens = tokens[i+1:]
+			break
+		}
+	}
+	if len(tokens) > 0 && strings.HasPrefix(tokens[0], "/") {
+		tokens = tokens[1:]
+	}
+	if len(tokens) == 0 {
+		return "", nil, nil
+	}
+
+	var args, flags []string
+	for _, token := range tokens[1:] {
+		switch {
+		case len(token) > 1 && strings.HasPrefix(token, "+"):
+			flags = append(flags, strings.TrimPrefix(token, "+"))
+		case len(token) > 2 && strings.HasPrefix(token, "--"):
+			flags = append(flags, strings.TrimPrefix(token, "--"))
+		default:
+			args = append(args, token)
+		}
+	}
+	return tokens[0], args, flags
+}
+
+func firstOpening(closing rune) rune {
+	for opening, c := range closingQuotes {
+		if c == closing {
+			return opening
+		}
+	}
+	return closing
+}
@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		text   string
+		tokens []string
+		err    string
+	}{
+		{text: "", tokens: nil},
+		{text: "   ", tokens: nil},
+		{text: "price btc", tokens: []string{"price", "btc"}},
+		{text: "  chart\tbtc \n 30d  ", tokens: []string{"chart", "btc", "30d"}},
+		{text: `timezone "America/New_York"`, tokens: []string{"timezone", "America/New_York"}},
+		{text: `say "two words" 'and three more'`, tokens: []string{"say", "two words", "and three more"}},
+		{text: "say “curly quotes” ‘from slack’", tokens: []string{"say", "curly quotes", "from slack"}},
+		{text: `say "it's"`, tokens: []string{"say", "it's"}},
+		{text: `don't "stop"`, tokens: []string{"don't", "stop"}},
+		{text: `say ""`, tokens: []string{"say", ""}},
+		{text: "<@U123> price btc", tokens: []string{"<@U123>", "price", "btc"}},
+		{text: "see <https://example.com|an example> now", tokens: []string{"see", "<https://example.com|an example>", "now"}},
+		{text: "chart btc 30d +volume --mcap", tokens: []string{"chart", "btc", "30d", "+volume", "--mcap"}},
+		{text: "précio ₿ 🚀 ünïcödé", tokens: []string{"précio", "₿", "🚀", "ünïcödé"}},
+		{text: "say «guillemets»", tokens: []string{"say", "«guillemets»"}},
+		{text: "a b　c", tokens: []string{"a", "b", "c"}},
+		{text: `say "unclosed`, err: `there is a " without its closing "`},
+		{text: "say ‘unclosed", err: "there is a ‘ without its closing ’"},
+		{text: "see <https://example.com", tokens: []string{"see", "<https://example.com"}},
+	}
+	for _, test := range tests {
+		tokens, err := Tokenize(test.text)
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("Tokenize(%q) error = %v, want %q", test.text, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Tokenize(%q) failed: %v", test.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(tokens, test.tokens) {
+			t.Errorf("Tokenize(%q) = %q, want %q", test.text, tokens, test.tokens)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		tokens []string
+		name   string
+		args   []string
+		flags  []string
+	}{
+		{tokens: []string{"<@bot>", "price", "btc"}, name: "price", args: []string{"btc"}},
+		{tokens: []string{"hey", "<@BOT>", "price", "btc"}, name: "price", args: []string{"btc"}},
+		{tokens: []string{"/crypto", "price", "btc"}, name: "price", args: []string{"btc"}},
+		{tokens: []string{"price", "btc"}, name: "price", args: []string{"btc"}},
+		{tokens: []string{"<@bot>"}, name: ""},
+		{tokens: nil, name: ""},
+		{
+			tokens: []string{"<@bot>", "chart", "btc", "+volume", "30d", "--mcap"},
+			name:   "chart",
+			args:   []string{"btc", "30d"},
+			flags:  []string{"volume", "mcap"},
+		},
+		// A lone + or -- and negative numbers are arguments
+		{tokens: []string{"<@bot>", "say", "+", "--", "-5", "-x"}, name: "say", args: []string{"+", "--", "-5", "-x"}},
+		{tokens: []string{"<@bot>", "chart", "+ünïcödé"}, name: "chart", flags: []string{"ünïcödé"}},
+	}
+	for _, test := range tests {
+		name, args, flags := Parse(test.tokens, "<@bot>")
+		if name != test.name || !reflect.DeepEqual(args, test.args) || !reflect.DeepEqual(flags, test.flags) {
+			t.Errorf("Parse(%q) = %q, %q, %q, want %q, %q, %q", test.tokens, name, args, flags, test.name, test.args, test.flags)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+// Package decimal provides exact decimal numbers for money and quantities, backed by math/big rationals so
+// additions and multiplications never lose precision the way float64 does
+package decimal
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+)
+
+// numberPattern Plain decimal numbers with an optional exponent. big.Rat would also read fractions and base prefixes
+// (1/3, 0x10), and exponents are kept to 3 digits so a typo can't build a huge number
+var numberPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d{1,3})?$`)
+
+// Decimal An immutable exact number. The zero value is 0
+type Decimal struct {
+	rat *big.Rat
+}
+
+var Zero = Decimal{}
+
+// New Creates a decimal from an integer
+func New(value int64) Decimal {
+	return Decimal{rat: new(big.Rat).SetInt64(value)}
+}
+
+// NewFromString Parses numbers like 42, -0.5, 30000.25 or 1e-8
+func NewFromString(value string) (Decimal, error) {
+	value = strings.TrimSpace(value)
+	if !numberPattern.MatchString(value) {
+		return Zero, fmt.Errorf("%q is not a valid number", value)
+	}
+	rat, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return Zero, fmt.Errorf("%q is not a valid number", value)
+	}
+	return Decimal{rat: rat}, nil
+}
+
+// RequireFromString Same as NewFromString but panics, meant for constants
+func RequireFromString(value string) Decimal {
+	d, err := NewFromString(value)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// NewFromFloat Converts a float64 using its shortest decimal representation, so 0.1 is exactly 0.1
+func NewFromFloat(value float64) Decimal {
+	d, err := NewFromString(fmt.Sprintf("%v", value))
+	if err != nil {
+		// Very big or small floats are written with exponent
+		return Decimal{rat: new(big.Rat).SetFloat64(value)}
+	}
+	return d
+}
+
+func (d Decimal) value() *big.Rat {
+	if d.rat == nil {
+		return new(big.Rat)
+	}
+	return d.rat
+}
+
+func (d Decimal) Add(other Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Add(d.value(), other.value())}
+}
+
+func (d Decimal) Sub(other Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Sub(d.value(), other.value())}
+}
+
+func (d Decimal) Mul(other Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Mul(d.value(), other.value())}
+}
+
+// Div Divides by other, which must not be zero
+func (d Decimal) Div(other Decimal) Decimal {
+	return Decimal{rat: new(big.Rat).Quo(d.value(), other.value())}
+}
+
+func (d Decimal) Neg() Decimal {
+	return Decimal{rat: new(big.Rat).Neg(d.value())}
+}
+
+func (d Decimal) Abs() Decimal {
+	return Decimal{rat: new(big.Rat).Abs(d.value())}
+}
+
+// Cmp Returns -1, 0 or 1 when d is lower, equal or greater than other
+func (d Decimal) Cmp(other Decimal) int {
+	return d.value().Cmp(other.value())
+}
+
+func (d Decimal) Equal(other Decimal) bool {
+	return d.Cmp(other) == 0
+}
+
+func (d Decimal) LessThan(other Decimal) bool {
+	return d.Cmp(other) < 0
+}
+
+func (d Decimal) GreaterThan(other Decimal) bool {
+	return d.Cmp(other) > 0
+}
+
+// Sign Returns -1, 0 or 1 for negative, zero or positive numbers
+func (d Decimal) Sign() int {
+	return d.value().Sign()
+}
+
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+func (d Decimal) Float64() float64 {
+	f, _ := d.value().Float64()
+	return f
+}
+
+// StringFixed Rounds to places decimals (half away from zero)
+func (d Decimal) StringFixed(places int) string {
+	s := d.value().FloatString(places)
+	// Negatives that round to zero lose their sign
+	if strings.Trim(s, "-0.") == "" {
+		return strings.TrimPrefix(s, "-")
+	}
+	return s
+}
+
+// String Exact representation, without trailing zeros. Numbers that can't be written with up to 18 decimals are rounded
+func (d Decimal) String() string {
+	s := d.value().FloatString(18)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// Min Returns the lowest of both
+func Min(a Decimal, b Decimal) Decimal {
+	if a.LessThan(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,147 @@
+package decimal
+
+import "testing"
+
+func TestNewFromString(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"42", "42"},
+		{"+42", "42"},
+		{"-0.5", "-0.5"},
+		{"30000.25", "30000.25"},
+		{" 7.10 ", "7.1"},
+		{".5", "0.5"},
+		{"5.", "5"},
+		{"-0", "0"},
+		{"010", "10"},
+		{"1e3", "1000"},
+		{"1E-8", "0.00000001"},
+		{"-2.5e+2", "-250"},
+		{"0.000000000000000000123", "0"},
+		{"123456789012345678901234567890.5", "123456789012345678901234567890.5"},
+	}
+	for _, test := range tests {
+		d, err := NewFromString(test.value)
+		if err != nil {
+			t.Errorf("NewFromString(%q) failed: %v", test.value, err)
+			continue
+		}
+		if d.String() != test.want {
+			t.Errorf("NewFromString(%q) = %s, want %s", test.value, d, test.want)
+		}
+	}
+}
+
+func TestNewFromStringInvalid(t *testing.T) {
+	for _, value := range []string{"", " ", "abc", "1,000", "1/3", "0x10", "0b1", "1_000", "1e", "1e1000", "--1", "- 1",
+		"1.2.3", ".", "Inf", "NaN", "$5"} {
+		_, err := NewFromString(value)
+		if err == nil {
+			t.Errorf("NewFromString(%q) didn't fail", value)
+		}
+	}
+	_, err := NewFromString("12abc")
+	if want := `"12abc" is not a valid number`; err == nil || err.Error() != want {
+		t.Errorf("NewFromString(12abc) error = %v, want %s", err, want)
+	}
+}
+
+func TestRounding(t *testing.T) {
+	tests := []struct {
+		value  string
+		places int
+		want   string
+	}{
+		// Halves go away from zero, not to the even digit
+		{"0.5", 0, "1"},
+		{"1.5", 0, "2"},
+		{"2.5", 0, "3"},
+		{"-2.5", 0, "-3"},
+		{"0.125", 2, "0.13"},
+		{"0.135", 2, "0.14"},
+		{"-0.125", 2, "-0.13"},
+		{"0.124999", 2, "0.12"},
+		{"-1.994", 2, "-1.99"},
+		{"-1.995", 2, "-2.00"},
+		{"1", 2, "1.00"},
+		{"-0.004", 2, "0.00"},
+		{"-0.005", 2, "-0.01"},
+		{"0", 2, "0.00"},
+	}
+	for _, test := range tests {
+		got := RequireFromString(test.value).StringFixed(test.places)
+		if got != test.want {
+			t.Errorf("%s.StringFixed(%d) = %s, want %s", test.value, test.places, got, test.want)
+		}
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		value Decimal
+		want  string
+	}{
+		{Zero, "0"},
+		{New(-3), "-3"},
+		{RequireFromString("1.500"), "1.5"},
+		{RequireFromString("-0.0000000000000000004"), "0"},
+		{RequireFromString("0.0000000000000000005"), "0.000000000000000001"},
+		{RequireFromString("-0.0000000000000000005"), "-0.000000000000000001"},
+		{New(1).Div(New(3)), "0.333333333333333333"},
+		{New(-2).Div(New(3)), "-0.666666666666666667"},
+		{NewFromFloat(0.1), "0.1"},
+		{NewFromFloat(1e21), "1000000000000000000000"},
+	}
+	for _, test := range tests {
+		if got := test.value.String(); got != test.want {
+			t.Errorf("String() = %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestDivByZero(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("dividing by zero didn't panic")
+		}
+	}()
+	New(1).Div(Zero)
+}
+
+func TestRoundTrip(t *testing.T) {
+	a := RequireFromString("0.1")
+	b := RequireFromString("0.2")
+	if sum := a.Add(b); !sum.Equal(RequireFromString("0.3")) {
+		t.Errorf("0.1 + 0.2 = %s, want 0.3", sum)
+	}
+
+	values := []string{"30000.25", "-0.5", "0.00000001", "123456789.987654321", "0"}
+	for _, x := range values {
+		for _, y := range values {
+			a, b := RequireFromString(x), RequireFromString(y)
+			if got := a.Add(b).Sub(b); !got.Equal(a) {
+				t.Errorf("%s + %s - %s = %s", x, y, y, got)
+			}
+			if got := a.Sub(b).Add(b); !got.Equal(a) {
+				t.Errorf("%s - %s + %s = %s", x, y, y, got)
+			}
+			if b.IsZero() {
+				continue
+			}
+			if got := a.Mul(b).Div(b); !got.Equal(a) {
+				t.Errorf("%s * %s / %s = %s", x, y, y, got)
+			}
+		}
+	}
+
+	// The zero value works like any other 0
+	var zero Decimal
+	if got := zero.Add(New(5)).Mul(New(2)); got.String() != "10" {
+		t.Errorf("(0 + 5) * 2 = %s, want 10", got)
+	}
+	if !zero.Equal(New(0)) || zero.Sign() != 0 || !zero.Neg().IsZero() {
+		t.Error("the zero value isn't 0")
+	}
+}
@@ -0,0 +1,208 @@
+// Package downsample reduces the number of points of a [timestamp, value] series so it can be
+// charted, trying to keep its visual shape (spikes and dips included)
+package downsample
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+type Strategy string
+
+const (
+	// LTTB Largest-Triangle-Three-Buckets, keeps the points that shape the series the most
+	LTTB Strategy = "lttb"
+	// MinMax Keeps the lowest and highest point of every bucket
+	MinMax Strategy = "minmax"
+	// Nth Keeps one point every N, the fastest but it drops spikes
+	Nth Strategy = "nth"
+)
+
+// Default Strategy used when none is requested
+const Default = LTTB
+
+// ParseStrategy Gets the strategy from its name
+func ParseStrategy(name string) (Strategy, bool) {
+	switch Strategy(strings.ToLower(name)) {
+	case LTTB:
+		return LTTB, true
+	case MinMax:
+		return MinMax, true
+	case Nth:
+		return Nth, true
+	default:
+		return "", false
+	}
+}
+
+// Series Downsamples data (pairs of [x, y] sorted by x) to at most threshold points
+func Series(data [][]float64, threshold int, strategy Strategy) [][]float64 {
+	return Select(data, Indices(data, threshold, strategy))
+}
+
+// Select Keeps the points of data at the given indices
+func Select(data [][]float64, indices []int) [][]float64 {
+	selected := make([][]float64, len(indices))
+	for i, index := range indices {
+		selected[i] = data[index]
+	}
+	return selected
+}
+
+// Indices Gets the indices of the points of data kept by the strategy, in ascending order
+func Indices(data [][]float64, threshold int, strategy Strategy) []int {
+	if threshold >= len(data) || threshold <= 0 {
+		return allIndices(len(data))
+	}
+	switch strategy {
+	case MinMax:
+		return minMaxIndices(data, threshold)
+	case Nth:
+		return nthIndices(data, threshold)
+	default:
+		return lttbIndices(data, threshold)
+	}
+}
+
+// MultiIndices Downsamples several series that share their x values, keeping the union of the points
+// each series needs so every one of them keeps its shape. The result has at most threshold indices
+func MultiIndices(series [][][]float64, threshold int, strategy Strategy) []int {
+	if len(series) == 0 {
+		return nil
+	}
+	perSeries := threshold / len(series)
+	if perSeries < 3 {
+		perSeries = 3
+	}
+	seen := make(map[int]bool)
+	var indices []int
+	for _, data := range series {
+		for _, index := range Indices(data, perSeries, strategy) {
+			if !seen[index] {
+				seen[index] = true
+				indices = append(indices, index)
+			}
+		}
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+func allIndices(n int) []int {
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	return indices
+}
+
+// nthIndices Keeps one point every N, and the last one so the series still spans the whole range
+func nthIndices(data [][]float64, threshold int) []int {
+	n := len(data)
+	if threshold < 2 {
+		return []int{0}
+	}
+	step := int(math.Ceil(float64(n-1) / float64(threshold-1)))
+	var indices []int
+	for i := 0; i < n-1; i += step {
+		indices = append(indices, i)
+	}
+	return append(indices, n-1)
+}
+
+// lttbIndices See https://skemman.is/bitstream/1946/15343/3/SS_MSthesis.pdf
+func lttbIndices(data [][]float64, threshold int) []int {
+	if threshold < 3 {
+		return nthIndices(data, threshold)
+	}
+	n := len(data)
+	indices := make([]int, 0, threshold)
+	indices = append(indices, 0)
+
+	// The first and last points are always kept, the rest is split in threshold - 2 buckets
+	every := float64(n-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		// Average point of the next bucket, used as the third vertex of the triangle
+		avgStart := int(math.Floor(float64(i+1)*every)) + 1
+		avgEnd := int(math.Floor(float64(i+2)*every)) + 1
+		if avgEnd > n {
+			avgEnd = n
+		}
+		avgX, avgY := 0.0, 0.0
+		for j := avgStart; j < avgEnd; j++ {
+			avgX += data[j][0]
+			avgY += data[j][1]
+		}
+		if count := float64(avgEnd - avgStart); count > 0 {
+			avgX /= count
+			avgY /= count
+		} else {
+			avgX, avgY = data[n-1][0], data[n-1][1]
+		}
+
+		// Point of the current bucket making the largest triangle with the last kept one and the average
+		rangeStart := int(math.Floor(float64(i)*every)) + 1
+		rangeEnd := int(math.Floor(float64(i+1)*every)) + 1
+		maxArea := -1.0
+		next := rangeStart
+		for j := rangeStart; j < rangeEnd; j++ {
+			area := math.Abs((data[a][0]-avgX)*(data[j][1]-data[a][1]) - (data[a][0]-data[j][0])*(avgY-data[a][1]))
+			if area > maxArea {
+				maxArea = area
+				next = j
+			}
+		}
+		indices = append(indices, next)
+		a = next
+	}
+
+	return append(indices, n-1)
+}
+
+// minMaxIndices Keeps the lowest and highest point of every bucket, and the first and last points so the series
+// still spans the whole range
+func minMaxIndices(data [][]float64, threshold int) []int {
+	// Two points are saved for the first and last ones
+	buckets := (threshold - 2) / 2
+	if buckets < 1 {
+		return nthIndices(data, threshold)
+	}
+	size := float64(len(data)) / float64(buckets)
+	indices := make([]int, 0, threshold)
+	for b := 0; b < buckets; b++ {
+		start := int(math.Floor(float64(b) * size))
+		end := int(math.Floor(float64(b+1) * size))
+		if b == buckets-1 {
+			end = len(data)
+		}
+		if start >= end {
+			continue
+		}
+		minIndex, maxIndex := start, start
+		for j := start; j < end; j++ {
+			if data[j][1] < data[minIndex][1] {
+				minIndex = j
+			}
+			if data[j][1] > data[maxIndex][1] {
+				maxIndex = j
+			}
+		}
+		switch {
+		case minIndex == maxIndex:
+			indices = append(indices, minIndex)
+		case minIndex < maxIndex:
+			indices = append(indices, minIndex, maxIndex)
+		default:
+			indices = append(indices, maxIndex, minIndex)
+		}
+	}
+	if indices[0] != 0 {
+		indices = append([]int{0}, indices...)
+	}
+	if indices[len(indices)-1] != len(data)-1 {
+		indices = append(indices, len(data)-1)
+	}
+	return indices
+}
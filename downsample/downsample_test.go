@@ -0,0 +1,140 @@
+package downsample
+
+import (
+	"math"
+	"sort"
+	"testing"
+)
+
+// yearOfMinutes Points of a year of minute prices, the largest series charted
+const yearOfMinutes = 525600
+
+// chartPoints Points kept for a chart
+const chartPoints = 1000
+
+var strategies = []Strategy{LTTB, MinMax, Nth}
+
+// wave A smooth series of n points, one per minute
+func wave(n int) [][]float64 {
+	data := make([][]float64, n)
+	for i := range data {
+		data[i] = []float64{float64(i) * 60000, 30000 + 1000*math.Sin(float64(i)/5000)}
+	}
+	return data
+}
+
+// checkIndices Fails unless indices are strictly ascending, within data and at most threshold
+func checkIndices(t *testing.T, indices []int, n int, threshold int) {
+	t.Helper()
+	if len(indices) > threshold {
+		t.Fatalf("kept %d points, want at most %d", len(indices), threshold)
+	}
+	for i, index := range indices {
+		if index < 0 || index >= n {
+			t.Fatalf("index %d out of range [0, %d)", index, n)
+		}
+		if i > 0 && index <= indices[i-1] {
+			t.Fatalf("indices not strictly ascending at %d: %d after %d", i, index, indices[i-1])
+		}
+	}
+}
+
+func contains(indices []int, index int) bool {
+	position := sort.SearchInts(indices, index)
+	return position < len(indices) && indices[position] == index
+}
+
+func TestIndicesKeepFirstAndLast(t *testing.T) {
+	data := wave(10000)
+	for _, strategy := range strategies {
+		for _, threshold := range []int{2, 3, 4, 5, 10, 99, 1000} {
+			indices := Indices(data, threshold, strategy)
+			checkIndices(t, indices, len(data), threshold)
+			if indices[0] != 0 || indices[len(indices)-1] != len(data)-1 {
+				t.Errorf("%s with %d points: kept %d to %d, want 0 to %d",
+					strategy, threshold, indices[0], indices[len(indices)-1], len(data)-1)
+			}
+		}
+	}
+}
+
+func TestIndicesKeepEverythingUnderThreshold(t *testing.T) {
+	data := wave(50)
+	for _, strategy := range strategies {
+		for _, threshold := range []int{0, -1, 50, 100} {
+			if indices := Indices(data, threshold, strategy); len(indices) != len(data) {
+				t.Errorf("%s with %d points: kept %d, want all %d", strategy, threshold, len(indices), len(data))
+			}
+		}
+	}
+}
+
+func TestIndicesKeepSpike(t *testing.T) {
+	for _, strategy := range []Strategy{LTTB, MinMax} {
+		for _, spike := range []float64{1e6, -1e6} {
+			data := wave(100000)
+			position := 43210
+			data[position][1] = spike
+			indices := Indices(data, 200, strategy)
+			if !contains(indices, position) {
+				t.Errorf("%s dropped the spike of %v at %d", strategy, spike, position)
+			}
+		}
+	}
+}
+
+func TestMultiIndicesAligned(t *testing.T) {
+	first, second := wave(20000), wave(20000)
+	// Each series has a spike the other one doesn't
+	first[1234][1] = 1e6
+	second[17890][1] = -1e6
+	series := [][][]float64{first, second}
+
+	for _, strategy := range []Strategy{LTTB, MinMax} {
+		indices := MultiIndices(series, 300, strategy)
+		checkIndices(t, indices, len(first), 300)
+		for _, position := range []int{1234, 17890} {
+			if !contains(indices, position) {
+				t.Errorf("%s dropped the spike at %d", strategy, position)
+			}
+		}
+		// The same indices keep every series on the same timestamps
+		a, b := Select(first, indices), Select(second, indices)
+		for i := range a {
+			if a[i][0] != b[i][0] {
+				t.Fatalf("%s: point %d at %v and %v", strategy, i, a[i][0], b[i][0])
+			}
+		}
+	}
+}
+
+func TestParseStrategy(t *testing.T) {
+	for name, want := range map[string]Strategy{"lttb": LTTB, "MinMax": MinMax, "NTH": Nth} {
+		if got, ok := ParseStrategy(name); !ok || got != want {
+			t.Errorf("ParseStrategy(%q) = %q, %v, want %q", name, got, ok, want)
+		}
+	}
+	if _, ok := ParseStrategy("average"); ok {
+		t.Errorf("ParseStrategy(%q) accepted an unknown strategy", "average")
+	}
+}
+
+func benchmarkIndices(b *testing.B, strategy Strategy) {
+	data := wave(yearOfMinutes)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Indices(data, chartPoints, strategy)
+	}
+}
+
+func BenchmarkLTTB(b *testing.B) {
+	benchmarkIndices(b, LTTB)
+}
+
+func BenchmarkMinMax(b *testing.B) {
+	benchmarkIndices(b, MinMax)
+}
+
+func BenchmarkNth(b *testing.B) {
+	benchmarkIndices(b, Nth)
+}
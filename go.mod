@@ -1,9 +1,10 @@
 module crypto-bot
 
-go 1.13
+go 1.17
 
 require (
-	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/joho/godotenv v1.4.0
 	github.com/slack-go/slack v0.11.4
 )
+
+require github.com/gorilla/websocket v1.5.0 // indirect
@@ -0,0 +1,89 @@
+package importers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// binance Spot trade history of Binance. The fee has its asset after the amount (0.001BNB), older exports have it
+// in a fee coin column instead
+type binance struct{}
+
+// binanceQuotes Quote currencies a pair may end with, the longest first so BTCUSDT isn't read as BTCU/SDT
+var binanceQuotes = []string{"USDT", "BUSD", "USDC", "USD", "BTC", "ETH", "BNB", "EUR"}
+
+func init() {
+	Register(binance{})
+}
+
+func (binance) Name() string {
+	return "Binance"
+}
+
+func (binance) Detect(header []string) bool {
+	return HasColumns(header, "date(utc)", "pair", "side", "price", "executed")
+}
+
+func (binance) Parse(row map[string]string) (Record, bool, error) {
+	var side string
+	switch strings.ToLower(row["side"]) {
+	case "buy":
+		side = Buy
+	case "sell":
+		side = Sell
+	default:
+		return Record{}, false, fmt.Errorf("unknown side %q", row["side"])
+	}
+
+	pair := strings.ToUpper(row["pair"])
+	var asset, quote string
+	for _, candidate := range binanceQuotes {
+		if strings.HasSuffix(pair, candidate) && len(pair) > len(candidate) {
+			asset, quote = strings.TrimSuffix(pair, candidate), candidate
+			break
+		}
+	}
+	if asset == "" {
+		return Record{}, false, fmt.Errorf("I can't tell the assets of the pair %q", row["pair"])
+	}
+	if !IsUSDQuote(quote) {
+		return Record{}, false, fmt.Errorf("only USD-quoted pairs are supported, %s is quoted in %s", pair, quote)
+	}
+
+	date, err := ParseTime(row["date(utc)"], "2006-01-02 15:04:05")
+	if err != nil {
+		return Record{}, false, err
+	}
+	quantity, err := ParseNumber(row["executed"])
+	if err != nil {
+		return Record{}, false, err
+	}
+	price, err := ParseNumber(row["price"])
+	if err != nil {
+		return Record{}, false, err
+	}
+	fee, err := ParseFee(row["fee"])
+	if err != nil {
+		return Record{}, false, err
+	}
+
+	record := Record{
+		Date:     date,
+		Side:     side,
+		Asset:    asset,
+		Quantity: quantity,
+		Price:    price,
+	}
+	feeAsset := firstNotEmpty(strings.ToUpper(row["fee coin"]), Unit(row["fee"]))
+	switch {
+	case IsUSDQuote(feeAsset):
+		record.Fee = fee
+	case feeAsset == asset && side == Buy:
+		// The fee was taken from the coins bought
+		record.Quantity = quantity.Sub(fee)
+	case feeAsset == asset:
+		record.Fee = fee.Mul(price)
+	}
+	// Fees paid in other assets (like BNB) have no price here, so they are left out
+	return record, true, nil
+}
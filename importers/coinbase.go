@@ -0,0 +1,74 @@
+package importers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// coinbase Transaction history report of Coinbase. Older exports name the price columns "Spot Price ..." and the fee
+// column "Fees"
+type coinbase struct{}
+
+func init() {
+	Register(coinbase{})
+}
+
+func (coinbase) Name() string {
+	return "Coinbase"
+}
+
+func (coinbase) Detect(header []string) bool {
+	return HasColumns(header, "timestamp", "transaction type", "asset", "quantity transacted")
+}
+
+func (coinbase) Parse(row map[string]string) (Record, bool, error) {
+	var side string
+	switch strings.ToLower(row["transaction type"]) {
+	case "buy", "advanced trade buy":
+		side = Buy
+	case "sell", "advanced trade sell":
+		side = Sell
+	default:
+		// Sends, receives, rewards... aren't trades
+		return Record{}, false, nil
+	}
+
+	currency := firstNotEmpty(row["price currency"], row["spot price currency"])
+	if !IsUSDQuote(currency) {
+		return Record{}, false, fmt.Errorf("only USD prices are supported, this one is in %s", currency)
+	}
+	date, err := ParseTime(row["timestamp"], "2006-01-02T15:04:05Z", "2006-01-02 15:04:05 MST", "2006-01-02 15:04:05")
+	if err != nil {
+		return Record{}, false, err
+	}
+	quantity, err := ParseNumber(row["quantity transacted"])
+	if err != nil {
+		return Record{}, false, err
+	}
+	price, err := ParseNumber(firstNotEmpty(row["price at transaction"], row["spot price at transaction"]))
+	if err != nil {
+		return Record{}, false, err
+	}
+	fee, err := ParseFee(firstNotEmpty(row["fees and/or spread"], row["fees"]))
+	if err != nil {
+		return Record{}, false, err
+	}
+	return Record{
+		Date:     date,
+		Side:     side,
+		Asset:    row["asset"],
+		Quantity: quantity.Abs(),
+		Price:    price,
+		Fee:      fee,
+		Ref:      row["id"],
+	}, true, nil
+}
+
+func firstNotEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package importers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// generic A plain CSV for any other exchange or for spreadsheets: date,side,asset,quantity,price (in USD), with
+// optional fee (in USD) and id columns
+type generic struct{}
+
+func init() {
+	Register(generic{})
+}
+
+func (generic) Name() string {
+	return "Generic"
+}
+
+func (generic) Detect(header []string) bool {
+	return HasColumns(header, "date", "side", "asset", "quantity", "price")
+}
+
+func (generic) Parse(row map[string]string) (Record, bool, error) {
+	side := strings.ToLower(row["side"])
+	if side != Buy && side != Sell {
+		return Record{}, false, fmt.Errorf("side must be buy or sell, not %q", row["side"])
+	}
+	date, err := ParseTime(row["date"], "2006-01-02T15:04:05Z07:00", "2006-01-02 15:04:05", "2006-01-02", "02-01-2006")
+	if err != nil {
+		return Record{}, false, err
+	}
+	quantity, err := ParseNumber(row["quantity"])
+	if err != nil {
+		return Record{}, false, err
+	}
+	if quantity.Sign() <= 0 {
+		return Record{}, false, fmt.Errorf("quantity must be positive, not %q", row["quantity"])
+	}
+	price, err := ParseNumber(row["price"])
+	if err != nil {
+		return Record{}, false, err
+	}
+	if price.Sign() < 0 {
+		return Record{}, false, fmt.Errorf("price can't be negative, it's %q", row["price"])
+	}
+	fee, err := ParseFee(row["fee"])
+	if err != nil {
+		return Record{}, false, err
+	}
+	return Record{
+		Date:     date,
+		Side:     side,
+		Asset:    row["asset"],
+		Quantity: quantity,
+		Price:    price,
+		Fee:      fee,
+		Ref:      row["id"],
+	}, true, nil
+}
@@ -0,0 +1,250 @@
+// Package importers reads the trade history exported by exchanges. Each format registers a Parser, and the one
+// that recognizes the CSV header is used to read the rows
+package importers
+
+import (
+	"crypto-bot/decimal"
+	"crypto/sha1"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	Buy  = "buy"
+	Sell = "sell"
+)
+
+// maxPreambleLines Some exports (like Coinbase) have a few lines of text before the header
+const maxPreambleLines = 10
+
+// Record A trade read from an export, priced in USD
+type Record struct {
+	Date     time.Time
+	Side     string
+	Asset    string
+	Quantity decimal.Decimal
+	// Price Per unit, Parse counts the fee in it so buys cost more and sells bring less
+	Price decimal.Decimal
+	// Fee Paid in USD for the trade, zero when it was paid in another asset or there was none
+	Fee decimal.Decimal
+	// Ref Identifies the trade so importing the same file twice doesn't duplicate it
+	Ref string
+}
+
+// RowError A row that couldn't be read
+type RowError struct {
+	Line int
+	Err  error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// Parser Reads one export format
+type Parser interface {
+	// Name Shown to the user, like Coinbase
+	Name() string
+	// Detect Tells whether the header (already lowered and trimmed) belongs to this format
+	Detect(header []string) bool
+	// Parse Reads a row given as column -> value. Rows that aren't trades (deposits, withdrawals...) return ok false
+	Parse(row map[string]string) (record Record, ok bool, err error)
+}
+
+var registry []Parser
+
+// Register Adds a parser, formats are detected in the order they were registered
+func Register(parser Parser) {
+	registry = append(registry, parser)
+}
+
+// Formats Names of the registered formats
+func Formats() []string {
+	names := make([]string, len(registry))
+	for i, parser := range registry {
+		names[i] = parser.Name()
+	}
+	return names
+}
+
+// Parse Detects the format of the CSV and reads its trades. Rows that fail are returned apart, so the rest can be imported
+func Parse(reader io.Reader) (string, []Record, []RowError, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+
+	var parser Parser
+	var header []string
+	for rows := 1; parser == nil; rows++ {
+		row, err := csvReader.Read()
+		if err == io.EOF || rows > maxPreambleLines {
+			return "", nil, nil, fmt.Errorf("I don't recognize the format of that file, I can read exports from %s", strings.Join(Formats(), ", "))
+		}
+		if err != nil {
+			continue
+		}
+		header = normalizeHeader(row)
+		for _, candidate := range registry {
+			if candidate.Detect(header) {
+				parser = candidate
+				break
+			}
+		}
+	}
+
+	var records []Record
+	var rowErrors []RowError
+	// occurrences Identical rows seen so far by their hash, partial fills of the same order can be identical
+	occurrences := make(map[string]int)
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := csvReader.FieldPos(0)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Line: line, Err: err})
+			continue
+		}
+		if isEmptyRow(row) {
+			continue
+		}
+		values := make(map[string]string, len(header))
+		for i, column := range header {
+			if i < len(row) {
+				values[column] = strings.TrimSpace(row[i])
+			}
+		}
+		record, ok, err := parser.Parse(values)
+		if err != nil {
+			rowErrors = append(rowErrors, RowError{Line: line, Err: err})
+			continue
+		}
+		if !ok {
+			continue
+		}
+		record.Asset = strings.ToUpper(record.Asset)
+		record.Price = priceWithFee(record)
+		if record.Ref == "" {
+			hash := hashRow(row)
+			// The first occurrence keeps the plain hash, like before identical rows were told apart
+			if occurrence := occurrences[hash]; occurrence > 0 {
+				record.Ref = fmt.Sprintf("%s-%d", hash, occurrence)
+			} else {
+				record.Ref = hash
+			}
+			occurrences[hash]++
+		}
+		record.Ref = strings.ToLower(parser.Name()) + ":" + record.Ref
+		records = append(records, record)
+	}
+	return parser.Name(), records, rowErrors, nil
+}
+
+// HasColumns Tells whether the header has every one of the columns
+func HasColumns(header []string, columns ...string) bool {
+	present := make(map[string]bool, len(header))
+	for _, column := range header {
+		present[column] = true
+	}
+	for _, column := range columns {
+		if !present[column] {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseNumber Reads a number that may have thousands separators, a currency symbol or a unit (0.5BTC, $1,000.00)
+func ParseNumber(value string) (decimal.Decimal, error) {
+	cleaned := strings.NewReplacer(",", "", "$", "", " ", "").Replace(value)
+	end := len(cleaned)
+	for end > 0 && !strings.ContainsRune("0123456789.", rune(cleaned[end-1])) {
+		end--
+	}
+	number, err := decimal.NewFromString(cleaned[:end])
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("%q is not a valid number", value)
+	}
+	return number, nil
+}
+
+// ParseFee Reads a fee column, empty when there was no fee. Fees are a cost, whatever their sign in the export
+func ParseFee(value string) (decimal.Decimal, error) {
+	if value == "" {
+		return decimal.Zero, nil
+	}
+	fee, err := ParseNumber(value)
+	if err != nil {
+		return decimal.Zero, err
+	}
+	return fee.Abs(), nil
+}
+
+// Unit The currency or asset written after a number, like the BTC of 0.5BTC
+func Unit(value string) string {
+	end := len(value)
+	for end > 0 && !strings.ContainsRune("0123456789. ", rune(value[end-1])) {
+		end--
+	}
+	return strings.ToUpper(value[end:])
+}
+
+// ParseTime Reads a date in any of the layouts, as UTC when it has no timezone
+func ParseTime(value string, layouts ...string) (time.Time, error) {
+	for _, layout := range layouts {
+		date, err := time.ParseInLocation(layout, value, time.UTC)
+		if err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a valid date", value)
+}
+
+// IsUSDQuote Tells whether the quote currency is the dollar or a dollar stablecoin
+func IsUSDQuote(currency string) bool {
+	switch strings.ToUpper(currency) {
+	case "USD", "USDT", "USDC", "BUSD", "ZUSD":
+		return true
+	default:
+		return false
+	}
+}
+
+func normalizeHeader(row []string) []string {
+	header := make([]string, len(row))
+	for i, column := range row {
+		header[i] = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\uFEFF")))
+	}
+	return header
+}
+
+func isEmptyRow(row []string) bool {
+	for _, value := range row {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// priceWithFee The price per unit once the fee is spread over the quantity
+func priceWithFee(record Record) decimal.Decimal {
+	if record.Fee.IsZero() || record.Quantity.IsZero() {
+		return record.Price
+	}
+	perUnit := record.Fee.Div(record.Quantity)
+	if record.Side == Sell {
+		return record.Price.Sub(perUnit)
+	}
+	return record.Price.Add(perUnit)
+}
+
+func hashRow(row []string) string {
+	sum := sha1.Sum([]byte(strings.Join(row, ",")))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,216 @@
+package importers
+
+import (
+	"crypto-bot/decimal"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFormats(t *testing.T) {
+	tests := []struct {
+		name    string
+		csv     string
+		format  string
+		records []Record
+		errors  []string
+	}{
+		{
+			name: "binance with the fee in USDT",
+			csv: "Date(UTC),Pair,Side,Price,Executed,Amount,Fee\n" +
+				"2022-03-01 10:00:00,BTCUSDT,SELL,40000,0.5BTC,20000USDT,20USDT\n",
+			format: "Binance",
+			records: []Record{
+				{Date: date(2022, 3, 1, 10), Side: Sell, Asset: "BTC", Quantity: number("0.5"), Price: number("39960"), Fee: number("20")},
+			},
+		},
+		{
+			name: "binance with the fee taken from the coins bought",
+			csv: "Date(UTC),Pair,Side,Price,Executed,Amount,Fee\n" +
+				"2022-03-01 10:00:00,ETHBUSD,BUY,3000,1ETH,3000BUSD,0.001ETH\n",
+			format: "Binance",
+			records: []Record{
+				{Date: date(2022, 3, 1, 10), Side: Buy, Asset: "ETH", Quantity: number("0.999"), Price: number("3000")},
+			},
+		},
+		{
+			name: "binance with the fee in BNB and a fee coin column",
+			csv: "Date(UTC),Pair,Side,Price,Executed,Fee,Fee Coin\n" +
+				"2022-03-01 10:00:00,SOLUSDC,BUY,100,2,0.01,BNB\n",
+			format: "Binance",
+			records: []Record{
+				{Date: date(2022, 3, 1, 10), Side: Buy, Asset: "SOL", Quantity: number("2"), Price: number("100")},
+			},
+		},
+		{
+			name: "binance pairs that aren't quoted in dollars",
+			csv: "Date(UTC),Pair,Side,Price,Executed\n" +
+				"2022-03-01 10:00:00,ETHBTC,BUY,0.07,1ETH\n" +
+				"2022-03-01 11:00:00,BTCUSDT,HOLD,40000,1BTC\n",
+			format: "Binance",
+			errors: []string{
+				"line 2: only USD-quoted pairs are supported, ETHBTC is quoted in BTC",
+				`line 3: unknown side "HOLD"`,
+			},
+		},
+		{
+			name: "coinbase after a preamble, skipping what isn't a trade",
+			csv: "You can use this transaction report to inform your likely tax obligations.\n" +
+				"\n" +
+				"Timestamp,Transaction Type,Asset,Quantity Transacted,Spot Price Currency,Spot Price at Transaction,Subtotal,Total (inclusive of fees and/or spread),Fees and/or Spread,Notes\n" +
+				"2022-03-01T10:00:00Z,Buy,BTC,0.01,USD,$40000.00,$400.00,$405.00,$5.00,Bought\n" +
+				"2022-03-02T10:00:00Z,Send,BTC,-0.005,USD,$41000.00,,,,Sent\n" +
+				"2022-03-03T10:00:00Z,Advanced Trade Sell,eth,-2,USD,\"$3,000.00\",,,$1.50,Sold\n",
+			format: "Coinbase",
+			records: []Record{
+				{Date: date(2022, 3, 1, 10), Side: Buy, Asset: "BTC", Quantity: number("0.01"), Price: number("40500"), Fee: number("5")},
+				{Date: date(2022, 3, 3, 10), Side: Sell, Asset: "ETH", Quantity: number("2"), Price: number("2999.25"), Fee: number("1.5")},
+			},
+		},
+		{
+			name: "coinbase with the newer price columns and an ID",
+			csv: "ID,Timestamp,Transaction Type,Asset,Quantity Transacted,Price Currency,Price at Transaction,Fees\n" +
+				"abc123,2022-03-01 10:00:00 UTC,Buy,ADA,100,USD,1,\n" +
+				"abc124,2022-03-01 10:00:00 UTC,Buy,ADA,100,EUR,1,\n",
+			format: "Coinbase",
+			records: []Record{
+				{Date: date(2022, 3, 1, 10), Side: Buy, Asset: "ADA", Quantity: number("100"), Price: number("1"), Ref: "coinbase:abc123"},
+			},
+			errors: []string{"line 3: only USD prices are supported, this one is in EUR"},
+		},
+		{
+			name: "kraken with the fee in dollars and its asset names",
+			csv: "txid,ordertxid,pair,time,type,ordertype,price,cost,fee,vol\n" +
+				"T1,O1,XXBTZUSD,2022-03-01 10:00:00.1234,buy,limit,40000,20000,32,0.5\n" +
+				"T2,O2,XLTCZUSD,2022-03-01 11:00:00,sell,market,100,1000,1.6,10\n" +
+				"T3,O3,DOTUSDT,2022-03-01 12:00:00,buy,market,20,200,,10\n" +
+				"T4,O4,XETHZEUR,2022-03-01 13:00:00,buy,market,2800,2800,,1\n",
+			format: "Kraken",
+			records: []Record{
+				{Date: date(2022, 3, 1, 10).Add(123400 * time.Microsecond), Side: Buy, Asset: "BTC", Quantity: number("0.5"), Price: number("40064"), Fee: number("32"), Ref: "kraken:T1"},
+				{Date: date(2022, 3, 1, 11), Side: Sell, Asset: "LTC", Quantity: number("10"), Price: number("99.84"), Fee: number("1.6"), Ref: "kraken:T2"},
+				{Date: date(2022, 3, 1, 12), Side: Buy, Asset: "DOT", Quantity: number("10"), Price: number("20"), Ref: "kraken:T3"},
+			},
+			errors: []string{"line 5: only USD-quoted pairs are supported, XETHZEUR is quoted in ZEUR"},
+		},
+		{
+			name: "generic with and without fees",
+			csv: "\uFEFFDate, Side ,Asset,Quantity,Price,Fee,ID\n" +
+				"2022-03-01,BUY,btc,0.1,\"$40,000\",$4,first\n" +
+				"01-03-2022,sell,eth,1,3000,,second\n" +
+				"2022-03-01T10:00:00-03:00,buy,sol,1,100,,third\n" +
+				",,,,,,\n",
+			format: "Generic",
+			records: []Record{
+				{Date: date(2022, 3, 1, 0), Side: Buy, Asset: "BTC", Quantity: number("0.1"), Price: number("40040"), Fee: number("4"), Ref: "generic:first"},
+				{Date: date(2022, 3, 1, 0), Side: Sell, Asset: "ETH", Quantity: number("1"), Price: number("3000"), Ref: "generic:second"},
+				{Date: date(2022, 3, 1, 13), Side: Buy, Asset: "SOL", Quantity: number("1"), Price: number("100"), Ref: "generic:third"},
+			},
+		},
+		{
+			name: "generic rows that can't be read",
+			csv: "date,side,asset,quantity,price\n" +
+				"2022-03-01,transfer,btc,1,40000\n" +
+				"2022/03/01,buy,btc,1,40000\n" +
+				"2022-03-01,buy,btc,0,40000\n" +
+				"2022-03-01,buy,btc,1,-5\n" +
+				"2022-03-01,buy,btc,one,40000\n",
+			format: "Generic",
+			errors: []string{
+				`line 2: side must be buy or sell, not "transfer"`,
+				`line 3: "2022/03/01" is not a valid date`,
+				`line 4: quantity must be positive, not "0"`,
+				`line 5: price can't be negative, it's "-5"`,
+				`line 6: "one" is not a valid number`,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			format, records, rowErrors, err := Parse(strings.NewReader(test.csv))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if format != test.format {
+				t.Errorf("format = %s, want %s", format, test.format)
+			}
+			if len(records) != len(test.records) {
+				t.Fatalf("got %d records, want %d: %+v", len(records), len(test.records), records)
+			}
+			for i, record := range records {
+				checkRecord(t, i, record, test.records[i])
+			}
+			if len(rowErrors) != len(test.errors) {
+				t.Fatalf("got the errors %v, want %v", rowErrors, test.errors)
+			}
+			for i, rowError := range rowErrors {
+				if rowError.Error() != test.errors[i] {
+					t.Errorf("error %d = %q, want %q", i, rowError.Error(), test.errors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	tests := []string{
+		"",
+		"when,what,how much\n2022-03-01,btc,1\n",
+		strings.Repeat("some text\n", maxPreambleLines) + "date,side,asset,quantity,price\n",
+	}
+	want := "I don't recognize the format of that file, I can read exports from Binance, Coinbase, Generic, Kraken"
+	for _, csv := range tests {
+		_, _, _, err := Parse(strings.NewReader(csv))
+		if err == nil || err.Error() != want {
+			t.Errorf("Parse(%q) error = %v, want %q", csv, err, want)
+		}
+	}
+}
+
+func TestIdenticalRows(t *testing.T) {
+	row := "2022-03-01 10:00:00,BTCUSDT,BUY,40000,0.1BTC,4000USDT,4USDT\n"
+	csv := "Date(UTC),Pair,Side,Price,Executed,Amount,Fee\n" + row + row + row
+	_, records, rowErrors, err := Parse(strings.NewReader(csv))
+	if err != nil || len(rowErrors) > 0 {
+		t.Fatal(err, rowErrors)
+	}
+	// Partial fills of the same order can be identical, each one is a trade of its own
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	refs := make(map[string]bool)
+	for _, record := range records {
+		refs[record.Ref] = true
+	}
+	if len(refs) != 3 {
+		t.Errorf("identical rows share their refs: %v", records)
+	}
+
+	// Importing the same file again gives the same refs, so they are found as duplicates
+	_, again, _, _ := Parse(strings.NewReader(csv))
+	for i := range again {
+		if again[i].Ref != records[i].Ref {
+			t.Errorf("the ref of row %d changed from %s to %s", i, records[i].Ref, again[i].Ref)
+		}
+	}
+}
+
+func date(year int, month time.Month, day int, hour int) time.Time {
+	return time.Date(year, month, day, hour, 0, 0, 0, time.UTC)
+}
+
+func number(value string) decimal.Decimal {
+	return decimal.RequireFromString(value)
+}
+
+// checkRecord Compares the records, and their refs only when one is expected since the others are hashes
+func checkRecord(t *testing.T, i int, got Record, want Record) {
+	t.Helper()
+	if !got.Date.Equal(want.Date) || got.Side != want.Side || got.Asset != want.Asset ||
+		!got.Quantity.Equal(want.Quantity) || !got.Price.Equal(want.Price) || !got.Fee.Equal(want.Fee) ||
+		(want.Ref != "" && got.Ref != want.Ref) {
+		t.Errorf("record %d = %v %s %s %s at %s (fee %s, ref %s), want %v %s %s %s at %s (fee %s, ref %s)", i,
+			got.Date, got.Side, got.Quantity, got.Asset, got.Price, got.Fee, got.Ref,
+			want.Date, want.Side, want.Quantity, want.Asset, want.Price, want.Fee, want.Ref)
+	}
+}
@@ -0,0 +1,91 @@
+package importers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// kraken Trades export of Kraken, the fee is in the quote currency
+type kraken struct{}
+
+// krakenQuotes Quote currencies a pair may end with, Kraken prefixes fiat with Z (XXBTZUSD)
+var krakenQuotes = []string{"ZUSD", "USDT", "USDC", "USD", "ZEUR", "EUR", "XXBT", "XBT"}
+
+// krakenAssets Kraken names that differ from the usual symbols
+var krakenAssets = map[string]string{
+	"XBT":  "BTC",
+	"XXBT": "BTC",
+	"XETH": "ETH",
+	"XXDG": "DOGE",
+}
+
+func init() {
+	Register(kraken{})
+}
+
+func (kraken) Name() string {
+	return "Kraken"
+}
+
+func (kraken) Detect(header []string) bool {
+	return HasColumns(header, "txid", "pair", "time", "type", "price", "vol")
+}
+
+func (kraken) Parse(row map[string]string) (Record, bool, error) {
+	var side string
+	switch strings.ToLower(row["type"]) {
+	case "buy":
+		side = Buy
+	case "sell":
+		side = Sell
+	default:
+		return Record{}, false, fmt.Errorf("unknown type %q", row["type"])
+	}
+
+	pair := strings.ToUpper(row["pair"])
+	var asset, quote string
+	for _, candidate := range krakenQuotes {
+		if strings.HasSuffix(pair, candidate) && len(pair) > len(candidate) {
+			asset, quote = strings.TrimSuffix(pair, candidate), candidate
+			break
+		}
+	}
+	if asset == "" {
+		return Record{}, false, fmt.Errorf("I can't tell the assets of the pair %q", row["pair"])
+	}
+	if !IsUSDQuote(quote) {
+		return Record{}, false, fmt.Errorf("only USD-quoted pairs are supported, %s is quoted in %s", pair, quote)
+	}
+	if name, found := krakenAssets[asset]; found {
+		asset = name
+	} else if len(asset) == 4 && strings.HasPrefix(asset, "X") {
+		// Old pairs prefix crypto with X, like XLTCZUSD
+		asset = asset[1:]
+	}
+
+	date, err := ParseTime(row["time"], "2006-01-02 15:04:05.9999", "2006-01-02 15:04:05")
+	if err != nil {
+		return Record{}, false, err
+	}
+	quantity, err := ParseNumber(row["vol"])
+	if err != nil {
+		return Record{}, false, err
+	}
+	price, err := ParseNumber(row["price"])
+	if err != nil {
+		return Record{}, false, err
+	}
+	fee, err := ParseFee(row["fee"])
+	if err != nil {
+		return Record{}, false, err
+	}
+	return Record{
+		Date:     date,
+		Side:     side,
+		Asset:    asset,
+		Quantity: quantity,
+		Price:    price,
+		Fee:      fee,
+		Ref:      row["txid"],
+	}, true, nil
+}
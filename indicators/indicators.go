@@ -0,0 +1,77 @@
+// Package indicators computes technical indicators over a price series. Every result has the same length as
+// the input, with NaN where there is not enough data yet (the first period-1 values)
+package indicators
+
+import "math"
+
+// SMA Simple moving average of the last period values
+func SMA(values []float64, period int) []float64 {
+	result := nanSlice(len(values))
+	if period <= 0 || period > len(values) {
+		return result
+	}
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			result[i] = sum / float64(period)
+		}
+	}
+	return result
+}
+
+// EMA Exponential moving average, seeded with the SMA of the first period values
+func EMA(values []float64, period int) []float64 {
+	result := nanSlice(len(values))
+	if period <= 0 || period > len(values) {
+		return result
+	}
+	k := 2 / float64(period+1)
+	sum := 0.0
+	for i := 0; i < period; i++ {
+		sum += values[i]
+	}
+	result[period-1] = sum / float64(period)
+	for i := period; i < len(values); i++ {
+		result[i] = values[i]*k + result[i-1]*(1-k)
+	}
+	return result
+}
+
+// StdDev Population standard deviation of the last period values
+func StdDev(values []float64, period int) []float64 {
+	result := nanSlice(len(values))
+	mean := SMA(values, period)
+	for i := period - 1; i >= 0 && i < len(values); i++ {
+		sum := 0.0
+		for _, v := range values[i-period+1 : i+1] {
+			sum += (v - mean[i]) * (v - mean[i])
+		}
+		result[i] = math.Sqrt(sum / float64(period))
+	}
+	return result
+}
+
+// BollingerBands Middle band (SMA) and the bands k standard deviations above and below it
+func BollingerBands(values []float64, period int, k float64) (middle []float64, upper []float64, lower []float64) {
+	middle = SMA(values, period)
+	deviation := StdDev(values, period)
+	upper = make([]float64, len(values))
+	lower = make([]float64, len(values))
+	for i := range values {
+		upper[i] = middle[i] + k*deviation[i]
+		lower[i] = middle[i] - k*deviation[i]
+	}
+	return middle, upper, lower
+}
+
+func nanSlice(n int) []float64 {
+	result := make([]float64, n)
+	for i := range result {
+		result[i] = math.NaN()
+	}
+	return result
+}
@@ -0,0 +1,138 @@
+package indicators
+
+import (
+	"math"
+	"testing"
+)
+
+// closes Daily closes of the StockCharts moving average example
+var closes = []float64{
+	22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29,
+	22.15, 22.39, 22.38, 22.61, 23.36, 24.05, 23.75, 23.83, 23.95, 23.63,
+	23.82, 23.87, 23.65, 23.19, 23.10, 23.33, 22.68, 23.10, 22.40, 22.17,
+}
+
+// bollingerCloses Daily closes of the StockCharts Bollinger Bands example
+var bollingerCloses = []float64{
+	86.16, 89.09, 88.78, 90.32, 89.07, 91.15, 89.44, 89.18, 86.93, 87.68,
+	86.96, 89.43, 89.32, 88.72, 87.45, 87.26, 89.50, 87.90, 89.13, 90.70,
+	92.90, 92.98, 91.80, 92.66, 92.68, 92.30, 92.77, 92.54, 92.95, 93.20,
+}
+
+// tolerance The reference values are rounded to cents
+const tolerance = 0.006
+
+// nan Marks a warm-up value in the expected results
+var nan = math.NaN()
+
+// checkValues Fails unless got has the length of want, NaN where want is NaN and the same values elsewhere
+func checkValues(t *testing.T, name string, got []float64, want []float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d values, want %d", name, len(got), len(want))
+	}
+	for i := range want {
+		switch {
+		case math.IsNaN(want[i]) && !math.IsNaN(got[i]):
+			t.Errorf("%s[%d] = %v, want NaN", name, i, got[i])
+		case !math.IsNaN(want[i]) && !(math.Abs(got[i]-want[i]) <= tolerance):
+			t.Errorf("%s[%d] = %v, want %v", name, i, got[i], want[i])
+		}
+	}
+}
+
+// warmUp Prefixes the values with the period-1 NaN of the warm-up
+func warmUp(period int, values ...float64) []float64 {
+	return append(nanSlice(period-1), values...)
+}
+
+func TestSMA(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		period int
+		want   []float64
+	}{
+		{"reference", closes, 10, warmUp(10,
+			22.22, 22.21, 22.23, 22.26, 22.30, 22.42, 22.61, 22.77, 22.91, 23.08, 23.21,
+			23.38, 23.52, 23.65, 23.71, 23.68, 23.61, 23.51, 23.43, 23.28, 23.13)},
+		{"period of one", []float64{1, 2, 3}, 1, []float64{1, 2, 3}},
+		{"period of the whole input", []float64{1, 2, 3}, 3, []float64{nan, nan, 2}},
+		{"period longer than the input", []float64{1, 2, 3}, 4, []float64{nan, nan, nan}},
+		{"zero period", []float64{1, 2, 3}, 0, []float64{nan, nan, nan}},
+		{"negative period", []float64{1, 2, 3}, -2, []float64{nan, nan, nan}},
+		{"empty input", nil, 3, []float64{}},
+	}
+	for _, test := range tests {
+		checkValues(t, "SMA "+test.name, SMA(test.values, test.period), test.want)
+	}
+}
+
+func TestEMA(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		period int
+		want   []float64
+	}{
+		{"reference", closes, 10, warmUp(10,
+			22.22, 22.21, 22.24, 22.27, 22.33, 22.52, 22.80, 22.97, 23.13, 23.28, 23.34,
+			23.43, 23.51, 23.53, 23.47, 23.40, 23.39, 23.26, 23.23, 23.08, 22.92)},
+		// k = 2 / (3 + 1), seeded with the SMA of 1, 2 and 3
+		{"seeded with the SMA", []float64{1, 2, 3, 5, 5}, 3, []float64{nan, nan, 2, 3.5, 4.25}},
+		{"period of one", []float64{1, 2, 3}, 1, []float64{1, 2, 3}},
+		{"period longer than the input", []float64{1, 2, 3}, 4, []float64{nan, nan, nan}},
+		{"zero period", []float64{1, 2, 3}, 0, []float64{nan, nan, nan}},
+		{"negative period", []float64{1, 2, 3}, -1, []float64{nan, nan, nan}},
+	}
+	for _, test := range tests {
+		checkValues(t, "EMA "+test.name, EMA(test.values, test.period), test.want)
+	}
+}
+
+func TestStdDev(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		period int
+		want   []float64
+	}{
+		{"reference", bollingerCloses, 20, warmUp(20,
+			1.29, 1.45, 1.69, 1.77, 1.90, 2.02, 2.08, 2.18, 2.24, 2.20, 2.19)},
+		{"population deviation", []float64{2, 4, 4, 4, 5, 5, 7, 9}, 8, warmUp(8, 2)},
+		{"constant input", []float64{3, 3, 3, 3}, 2, []float64{nan, 0, 0, 0}},
+		{"period longer than the input", []float64{1, 2, 3}, 4, []float64{nan, nan, nan}},
+		{"zero period", []float64{1, 2, 3}, 0, []float64{nan, nan, nan}},
+		{"negative period", []float64{1, 2, 3}, -3, []float64{nan, nan, nan}},
+	}
+	for _, test := range tests {
+		checkValues(t, "StdDev "+test.name, StdDev(test.values, test.period), test.want)
+	}
+}
+
+func TestBollingerBands(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		period int
+		k      float64
+		middle []float64
+		upper  []float64
+		lower  []float64
+	}{
+		{"reference", bollingerCloses, 20, 2,
+			warmUp(20, 88.71, 89.05, 89.24, 89.39, 89.51, 89.69, 89.75, 89.91, 90.08, 90.38, 90.66),
+			warmUp(20, 91.29, 91.95, 92.61, 92.93, 93.31, 93.73, 93.90, 94.26, 94.56, 94.79, 95.04),
+			warmUp(20, 86.13, 86.14, 85.87, 85.85, 85.70, 85.65, 85.59, 85.56, 85.60, 85.98, 86.27)},
+		{"period longer than the input", []float64{1, 2, 3}, 4, 2,
+			[]float64{nan, nan, nan}, []float64{nan, nan, nan}, []float64{nan, nan, nan}},
+		{"zero period", []float64{1, 2, 3}, 0, 2,
+			[]float64{nan, nan, nan}, []float64{nan, nan, nan}, []float64{nan, nan, nan}},
+	}
+	for _, test := range tests {
+		middle, upper, lower := BollingerBands(test.values, test.period, test.k)
+		checkValues(t, "BollingerBands middle "+test.name, middle, test.middle)
+		checkValues(t, "BollingerBands upper "+test.name, upper, test.upper)
+		checkValues(t, "BollingerBands lower "+test.name, lower, test.lower)
+	}
+}
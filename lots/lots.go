@@ -0,0 +1,185 @@
+// Package lots tracks the lots bought with each portfolio transaction to compute the realized gain of every
+// disposal under the FIFO, LIFO or average cost methods
+package lots
+
+import (
+	"crypto-bot/decimal"
+	"crypto-bot/utils"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Method string
+
+const (
+	// FIFO Sells the oldest lots first
+	FIFO Method = "fifo"
+	// LIFO Sells the newest lots first
+	LIFO Method = "lifo"
+	// Average Every unit costs the average of the pool, lots are still consumed oldest first for their dates
+	Average Method = "average"
+)
+
+// ParseMethod Gets the method from its name
+func ParseMethod(name string) (Method, bool) {
+	switch strings.ToLower(name) {
+	case "fifo":
+		return FIFO, true
+	case "lifo":
+		return LIFO, true
+	case "average", "avg", "acb":
+		return Average, true
+	default:
+		return "", false
+	}
+}
+
+// Lot Quantity of a crypto acquired at once, with what is left of it
+type Lot struct {
+	Crypto   string
+	Acquired time.Time
+	Quantity decimal.Decimal
+	UnitCost decimal.Decimal
+}
+
+// Disposal The part of a sell taken from one lot
+type Disposal struct {
+	Crypto   string
+	Acquired time.Time
+	Disposed time.Time
+	Quantity decimal.Decimal
+	Proceeds decimal.Decimal
+	Cost     decimal.Decimal
+}
+
+// Gain Proceeds minus cost
+func (d Disposal) Gain() decimal.Decimal {
+	return d.Proceeds.Sub(d.Cost)
+}
+
+// Result Open lots of each crypto (in acquisition order) and every disposal made
+type Result struct {
+	Open      map[string][]*Lot
+	Disposals []Disposal
+}
+
+// Compute Replays the transactions (sorted by date) matching every sell against the lots with the method.
+// It checks that, for every crypto, bought = sold + open and sold = disposed
+func Compute(transactions []utils.Transaction, method Method) (*Result, error) {
+	result := &Result{Open: make(map[string][]*Lot)}
+	bought := make(map[string]decimal.Decimal)
+	sold := make(map[string]decimal.Decimal)
+
+	for _, transaction := range transactions {
+		switch transaction.Side {
+		case utils.Buy:
+			bought[transaction.Crypto] = bought[transaction.Crypto].Add(transaction.Quantity)
+			result.Open[transaction.Crypto] = append(result.Open[transaction.Crypto], &Lot{
+				Crypto:   transaction.Crypto,
+				Acquired: transaction.Date,
+				Quantity: transaction.Quantity,
+				UnitCost: transaction.Price,
+			})
+			if method == Average {
+				averagePool(result.Open[transaction.Crypto])
+			}
+		case utils.Sell:
+			sold[transaction.Crypto] = sold[transaction.Crypto].Add(transaction.Quantity)
+			disposals, err := dispose(result, transaction, method)
+			if err != nil {
+				return nil, err
+			}
+			result.Disposals = append(result.Disposals, disposals...)
+		}
+	}
+
+	return result, reconcile(result, bought, sold)
+}
+
+// Disposed Disposals made within the year in loc, sorted by disposal date
+func (result *Result) Disposed(year int, loc *time.Location) []Disposal {
+	var disposals []Disposal
+	for _, disposal := range result.Disposals {
+		if disposal.Disposed.In(loc).Year() == year {
+			disposals = append(disposals, disposal)
+		}
+	}
+	sort.SliceStable(disposals, func(i, j int) bool {
+		return disposals[i].Disposed.Before(disposals[j].Disposed)
+	})
+	return disposals
+}
+
+// dispose Takes the sold quantity from the open lots, in the order of the method
+func dispose(result *Result, transaction utils.Transaction, method Method) ([]Disposal, error) {
+	open := result.Open[transaction.Crypto]
+	remaining := transaction.Quantity
+	var disposals []Disposal
+	for remaining.Sign() > 0 {
+		if len(open) == 0 {
+			return nil, fmt.Errorf("the sell of %s %s on %s is bigger than the quantity held", transaction.Quantity, transaction.Crypto, transaction.Date.Format("2006-01-02"))
+		}
+		index := 0
+		if method == LIFO {
+			index = len(open) - 1
+		}
+		lot := open[index]
+		quantity := decimal.Min(remaining, lot.Quantity)
+		disposals = append(disposals, Disposal{
+			Crypto:   transaction.Crypto,
+			Acquired: lot.Acquired,
+			Disposed: transaction.Date,
+			Quantity: quantity,
+			Proceeds: quantity.Mul(transaction.Price),
+			Cost:     quantity.Mul(lot.UnitCost),
+		})
+		lot.Quantity = lot.Quantity.Sub(quantity)
+		remaining = remaining.Sub(quantity)
+		if lot.Quantity.IsZero() {
+			open = append(open[:index], open[index+1:]...)
+		}
+	}
+	result.Open[transaction.Crypto] = open
+	return disposals, nil
+}
+
+// averagePool Sets every lot of the pool to the average unit cost of the pool
+func averagePool(pool []*Lot) {
+	quantity, cost := decimal.Zero, decimal.Zero
+	for _, lot := range pool {
+		quantity = quantity.Add(lot.Quantity)
+		cost = cost.Add(lot.Quantity.Mul(lot.UnitCost))
+	}
+	if quantity.IsZero() {
+		return
+	}
+	average := cost.Div(quantity)
+	for _, lot := range pool {
+		lot.UnitCost = average
+	}
+}
+
+func reconcile(result *Result, bought map[string]decimal.Decimal, sold map[string]decimal.Decimal) error {
+	disposed := make(map[string]decimal.Decimal)
+	for _, disposal := range result.Disposals {
+		disposed[disposal.Crypto] = disposed[disposal.Crypto].Add(disposal.Quantity)
+	}
+	for crypto, quantity := range bought {
+		open := decimal.Zero
+		for _, lot := range result.Open[crypto] {
+			if lot.Quantity.Sign() < 0 {
+				return fmt.Errorf("lots of %s don't reconcile: a lot has a negative quantity", crypto)
+			}
+			open = open.Add(lot.Quantity)
+		}
+		if !quantity.Equal(sold[crypto].Add(open)) {
+			return fmt.Errorf("lots of %s don't reconcile: bought %s, sold %s, open %s", crypto, quantity, sold[crypto], open)
+		}
+		if !sold[crypto].Equal(disposed[crypto]) {
+			return fmt.Errorf("lots of %s don't reconcile: sold %s, disposed %s", crypto, sold[crypto], disposed[crypto])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package lots
+
+import (
+	"crypto-bot/decimal"
+	"crypto-bot/utils"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+var methods = []Method{FIFO, LIFO, Average}
+
+var start = time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func transaction(day int, side string, crypto string, quantity string, price string) utils.Transaction {
+	return utils.Transaction{
+		User:     "tester",
+		Date:     start.AddDate(0, 0, day),
+		Side:     side,
+		Crypto:   crypto,
+		Quantity: decimal.RequireFromString(quantity),
+		Price:    decimal.RequireFromString(price),
+	}
+}
+
+// randomTransactions Buys and sells of two cryptos that never sell more than is held
+func randomTransactions(random *rand.Rand) []utils.Transaction {
+	held := map[string]int64{"BTC": 0, "ETH": 0}
+	var transactions []utils.Transaction
+	for day := 0; day < 60; day++ {
+		crypto := "BTC"
+		if random.Intn(2) == 0 {
+			crypto = "ETH"
+		}
+		// Quantities in thousandths, so the sells split lots at odd places
+		quantity := int64(random.Intn(5000) + 1)
+		side := utils.Buy
+		if held[crypto] > 0 && random.Intn(3) == 0 {
+			side = utils.Sell
+			if quantity > held[crypto] {
+				quantity = held[crypto]
+			}
+			held[crypto] -= quantity
+		} else {
+			held[crypto] += quantity
+		}
+		transactions = append(transactions, utils.Transaction{
+			User:     "tester",
+			Date:     start.AddDate(0, 0, day),
+			Side:     side,
+			Crypto:   crypto,
+			Quantity: decimal.New(quantity).Div(decimal.New(1000)),
+			Price:    decimal.New(int64(random.Intn(60000) + 1)).Div(decimal.New(100)),
+		})
+	}
+	return transactions
+}
+
+// checkReconciles Fails unless, for every crypto, bought = sold + open, sold = disposed, the cost bought = the cost
+// disposed + the cost open, and every open lot has a positive quantity
+func checkReconciles(t *testing.T, transactions []utils.Transaction, result *Result) {
+	t.Helper()
+	bought, sold, boughtCost := map[string]decimal.Decimal{}, map[string]decimal.Decimal{}, map[string]decimal.Decimal{}
+	for _, transaction := range transactions {
+		if transaction.Side == utils.Buy {
+			bought[transaction.Crypto] = bought[transaction.Crypto].Add(transaction.Quantity)
+			boughtCost[transaction.Crypto] = boughtCost[transaction.Crypto].Add(transaction.Quantity.Mul(transaction.Price))
+		} else {
+			sold[transaction.Crypto] = sold[transaction.Crypto].Add(transaction.Quantity)
+		}
+	}
+	disposed, disposedCost := map[string]decimal.Decimal{}, map[string]decimal.Decimal{}
+	for _, disposal := range result.Disposals {
+		if disposal.Quantity.Sign() <= 0 {
+			t.Fatalf("disposal of %s %s", disposal.Quantity, disposal.Crypto)
+		}
+		if disposal.Acquired.After(disposal.Disposed) {
+			t.Fatalf("%s disposed on %s from a lot acquired later, on %s", disposal.Crypto, disposal.Disposed, disposal.Acquired)
+		}
+		disposed[disposal.Crypto] = disposed[disposal.Crypto].Add(disposal.Quantity)
+		disposedCost[disposal.Crypto] = disposedCost[disposal.Crypto].Add(disposal.Cost)
+	}
+	for crypto := range bought {
+		open, openCost := decimal.Zero, decimal.Zero
+		for _, lot := range result.Open[crypto] {
+			if lot.Quantity.Sign() <= 0 {
+				t.Fatalf("open lot of %s %s", lot.Quantity, crypto)
+			}
+			open = open.Add(lot.Quantity)
+			openCost = openCost.Add(lot.Quantity.Mul(lot.UnitCost))
+		}
+		if !bought[crypto].Equal(sold[crypto].Add(open)) {
+			t.Fatalf("%s: bought %s, sold %s, open %s", crypto, bought[crypto], sold[crypto], open)
+		}
+		if !sold[crypto].Equal(disposed[crypto]) {
+			t.Fatalf("%s: sold %s, disposed %s", crypto, sold[crypto], disposed[crypto])
+		}
+		if !boughtCost[crypto].Equal(disposedCost[crypto].Add(openCost)) {
+			t.Fatalf("%s: cost bought %s, disposed %s, open %s", crypto, boughtCost[crypto], disposedCost[crypto], openCost)
+		}
+	}
+}
+
+func TestComputeRandomReconciles(t *testing.T) {
+	random := rand.New(rand.NewSource(38))
+	for i := 0; i < 200; i++ {
+		transactions := randomTransactions(random)
+		for _, method := range methods {
+			result, err := Compute(transactions, method)
+			if err != nil {
+				t.Fatalf("sequence %d with %s: %s", i, method, err)
+			}
+			checkReconciles(t, transactions, result)
+		}
+	}
+}
+
+func TestComputeGains(t *testing.T) {
+	transactions := []utils.Transaction{
+		transaction(0, utils.Buy, "BTC", "1", "100"),
+		transaction(1, utils.Buy, "BTC", "1", "200"),
+		transaction(2, utils.Sell, "BTC", "1.5", "300"),
+		transaction(3, utils.Buy, "BTC", "0.5", "300"),
+		transaction(4, utils.Sell, "BTC", "0.75", "400"),
+	}
+	tests := []struct {
+		method Method
+		// gains Of the first and second sells
+		gains    [2]string
+		openCost string
+	}{
+		// 1 at 100 and 0.5 at 100, then 0.5 at 200 and 0.25 at 300
+		{FIFO, [2]string{"250", "125"}, "75"},
+		// 1 at 200 and 0.5 at 100, then 0.5 at 300 and 0.25 at 100
+		{LIFO, [2]string{"200", "125"}, "25"},
+		// 1.5 at 150, then 0.75 at (0.5 * 150 + 0.5 * 300) / 1 = 225
+		{Average, [2]string{"225", "131.25"}, "56.25"},
+	}
+	for _, test := range tests {
+		result, err := Compute(transactions, test.method)
+		if err != nil {
+			t.Fatalf("%s: %s", test.method, err)
+		}
+		checkReconciles(t, transactions, result)
+		for i, day := range []int{2, 4} {
+			gain := decimal.Zero
+			for _, disposal := range result.Disposals {
+				if disposal.Disposed.Equal(start.AddDate(0, 0, day)) {
+					gain = gain.Add(disposal.Gain())
+				}
+			}
+			if want := decimal.RequireFromString(test.gains[i]); !gain.Equal(want) {
+				t.Errorf("%s: gain of sell %d = %s, want %s", test.method, i+1, gain, want)
+			}
+		}
+		openCost := decimal.Zero
+		for _, lot := range result.Open["BTC"] {
+			openCost = openCost.Add(lot.Quantity.Mul(lot.UnitCost))
+		}
+		if want := decimal.RequireFromString(test.openCost); !openCost.Equal(want) {
+			t.Errorf("%s: open cost = %s, want %s", test.method, openCost, want)
+		}
+	}
+}
+
+func TestComputeSellBiggerThanHeld(t *testing.T) {
+	transactions := []utils.Transaction{
+		transaction(0, utils.Buy, "ETH", "1", "2000"),
+		transaction(1, utils.Sell, "ETH", "1.01", "2500"),
+	}
+	for _, method := range methods {
+		if _, err := Compute(transactions, method); err == nil {
+			t.Errorf("%s: sold more than held without an error", method)
+		}
+	}
+}
+
+func TestDisposed(t *testing.T) {
+	transactions := []utils.Transaction{
+		transaction(0, utils.Buy, "SOL", "10", "20"),
+		transaction(364, utils.Sell, "SOL", "4", "30"),
+		transaction(365, utils.Sell, "SOL", "4", "40"),
+	}
+	transactions[1].Date = transactions[1].Date.Add(20 * time.Hour)
+	result, err := Compute(transactions, FIFO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first sell is on 2021-12-31 20:00 UTC, already 2022 in Tokyo
+	if disposals := result.Disposed(2021, time.UTC); len(disposals) != 1 {
+		t.Errorf("got %d disposals in 2021 UTC, want 1", len(disposals))
+	}
+	tokyo := time.FixedZone("JST", 9*60*60)
+	if disposals := result.Disposed(2022, tokyo); len(disposals) != 2 {
+		t.Errorf("got %d disposals in 2022 JST, want 2", len(disposals))
+	}
+}
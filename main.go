@@ -3,6 +3,9 @@ package main
 import (
 	"context"
 	"crypto-bot/actions"
+	"crypto-bot/commands"
+	"crypto-bot/render"
+	"crypto-bot/response"
 	"crypto-bot/utils"
 	"errors"
 	"fmt"
@@ -17,6 +20,12 @@ import (
 	"github.com/slack-go/slack/socketmode"
 )
 
+// botMention How the bot is mentioned, in lower case
+var botMention string
+
+// botUserID The bot user, to ignore its own messages in its DMs
+var botUserID string
+
 func main() {
 	// Load Env variables from .env file
 	err := godotenv.Load(".env")
@@ -31,6 +40,15 @@ func main() {
 	api := slack.New(token, slack.OptionDebug(true), slack.OptionAppLevelToken(appToken))
 	client := socketmode.New(api, socketmode.OptionDebug(false))
 
+	// Mentions start with the bot user, the command comes after it
+	auth, err := api.AuthTest()
+	if err != nil {
+		fmt.Println("Error authenticating with Slack: ", err)
+		return
+	}
+	botUserID = auth.UserID
+	botMention = "<@" + strings.ToLower(auth.UserID) + ">"
+
 	err = actions.InitMessage(api)
 	// Create a context that can be used to cancel goroutine
 	ctx, cancel := context.WithCancel(context.Background())
@@ -42,9 +60,23 @@ func main() {
 		// Every 10 seconds, we check the rules
 		go func() {
 			for range time.Tick(time.Second * 10) {
-				err := actions.VerifyRules(os.Getenv("ALARM_FILENAME"), api)
+				err := actions.VerifyRules(utils.RulesFileName(), api)
+				if err != nil {
+					log.Println("Error verifying the rules", err)
+				}
+				err = actions.VerifyPaperOrders(api)
+				if err != nil {
+					log.Println("Error verifying the paper orders", err)
+				}
+			}
+		}()
+
+		// Every minute, we post the digests that are due
+		go func() {
+			for range time.Tick(time.Minute) {
+				err := actions.RunDigests(api)
 				if err != nil {
-					log.Fatal(err)
+					log.Println("Error running the digests", err)
 				}
 			}
 		}()
@@ -74,8 +106,47 @@ func main() {
 					err := handleEventMessage(eventsAPIEvent, api)
 
 					if err != nil {
-						log.Fatal(err)
+						log.Println("Error", err)
+					}
+
+				// handle /crypto slash commands
+				case socketmode.EventTypeSlashCommand:
+					slashCommand, ok := event.Data.(slack.SlashCommand)
+					if !ok {
+						log.Printf("Could not type cast the event to the SlashCommand: %v\n", event)
+						continue
 					}
+					// Slack expects the Acknowledge within 3 seconds, the reply is sent later through the response URL
+					socketClient.Ack(*event.Request)
+					go func() {
+						err := handleSlashCommand(slashCommand, api)
+						if err != nil {
+							log.Println("Error", err)
+						}
+					}()
+
+				// handle the buttons of the replies
+				case socketmode.EventTypeInteractive:
+					callback, ok := event.Data.(slack.InteractionCallback)
+					if !ok {
+						log.Printf("Could not type cast the event to the InteractionCallback: %v\n", event)
+						continue
+					}
+					if callback.Type == slack.InteractionTypeViewSubmission {
+						// Validation errors must be in the Acknowledge to be shown in the modal
+						errs, valid := validateSubmission(callback)
+						if !valid {
+							socketClient.Ack(*event.Request, slack.NewErrorsViewSubmissionResponse(errs))
+							continue
+						}
+					}
+					socketClient.Ack(*event.Request)
+					go func() {
+						err := handleInteraction(callback, api)
+						if err != nil {
+							log.Println("Error", err)
+						}
+					}()
 				}
 			}
 		}
@@ -91,6 +162,16 @@ func handleEventMessage(event slackevents.EventsAPIEvent, api *slack.Client) err
 		innerEvent := event.InnerEvent
 		// Yet Another Type switch on the actual Data to see if it's an AppMentionEvent
 		switch ev := innerEvent.Data.(type) {
+		case *slackevents.AppHomeOpenedEvent:
+			if ev.Tab != "home" {
+				return nil
+			}
+			go func() {
+				err := handleHomeOpened(ev.User, api)
+				if err != nil {
+					log.Println("Error", err)
+				}
+			}()
 		case *slackevents.AppMentionEvent:
 			// The application has been mentioned since this Event is a Mention event
 			var err error
@@ -101,7 +182,17 @@ func handleEventMessage(event slackevents.EventsAPIEvent, api *slack.Client) err
 				log.Println("Error", err)
 				return err
 			}
-
+		case *slackevents.MessageEvent:
+			// Messages in the bot's DM are commands, without the need of a mention
+			if !isDirectMessageCommand(ev) {
+				return nil
+			}
+			go func() {
+				err := handleDirectMessage(ev, api)
+				if err != nil {
+					log.Println("Error", err)
+				}
+			}()
 		}
 	default:
 		return errors.New("unsupported event type")
@@ -110,67 +201,331 @@ func handleEventMessage(event slackevents.EventsAPIEvent, api *slack.Client) err
 }
 
 func handleEventMention(event *slackevents.AppMentionEvent, api *slack.Client) error {
-	// Grab the user's name based on the ID of the one who mentioned the bot
-	user, err := api.GetUserInfo(event.User)
-	if err != nil {
+	// The reply goes in the thread of the mention, so it doesn't clutter the channel
+	thread := event.ThreadTimeStamp
+	if thread == "" {
+		thread = event.TimeStamp
+	}
+	return replyToMessage(command{
+		text:          event.Text,
+		userID:        event.User,
+		channel:       event.Channel,
+		uploadChannel: event.Channel,
+		timestamp:     event.TimeStamp,
+		messageThread: event.ThreadTimeStamp,
+		thread:        thread,
+	}, api)
+}
+
+// isDirectMessageCommand Tells if the message was written by a user in a DM with the bot. The bot's own replies and
+// other bots are ignored, and so are edits and deletions
+func isDirectMessageCommand(event *slackevents.MessageEvent) bool {
+	if event.ChannelType != "im" || event.User == "" || event.User == botUserID || event.BotID != "" {
+		return false
+	}
+	// Files uploaded with the message, like the CSV of import, come as file_share
+	return event.SubType == "" || event.SubType == "file_share"
+}
+
+// handleDirectMessage Runs a command written in the bot's DM
+func handleDirectMessage(event *slackevents.MessageEvent, api *slack.Client) error {
+	return replyToMessage(command{
+		text:          event.Text,
+		userID:        event.User,
+		channel:       event.Channel,
+		uploadChannel: event.Channel,
+		timestamp:     event.TimeStamp,
+		messageThread: event.ThreadTimeStamp,
+		message:       directMessage(event),
+		thread:        event.ThreadTimeStamp,
+	}, api)
+}
+
+// directMessage The message of the event with its files, which message events include unlike app mentions
+func directMessage(event *slackevents.MessageEvent) *slack.Message {
+	message := &slack.Message{}
+	message.Timestamp = event.TimeStamp
+	message.User = event.User
+	for _, file := range event.Files {
+		message.Files = append(message.Files, slack.File{
+			ID:                 file.ID,
+			Name:               file.Name,
+			URLPrivateDownload: file.URLPrivateDownload,
+		})
+	}
+	return message
+}
+
+// replyToMessage Runs the command of a message and posts the reply in its channel, or its thread
+func replyToMessage(cmd command, api *slack.Client) error {
+	reply, err := runCommand(cmd, api)
+	if err != nil || reply == nil {
 		return err
 	}
 
-	mention := strings.ToLower(event.Text)
-	// Create a slice with the arguments
-	splitedText := strings.Split(mention, " ")
+	options := render.MsgOptions(reply)
+	if cmd.thread != "" {
+		options = append(options, slack.MsgOptionTS(cmd.thread))
+	}
+	_, _, err = api.PostMessage(cmd.channel, options...)
+	if err != nil {
+		return fmt.Errorf("failed to post message: %w", err)
+	}
 
-	action := splitedText[1]
-	date := utils.GetFormattedActualDate()
-	userName := user.Name
-	var text, pretext, color string
-	var attachment slack.Attachment
+	return nil
+}
 
-	// Add Some default context like user who mentioned the bot
-	fields := []slack.AttachmentField{
-		{
-			Title: "Date",
-			Value: date,
-		}, {
-			Title: "Initializer",
-			Value: userName,
-		},
+// handleHomeOpened Shows the user's dashboard every time they open the Home tab
+func handleHomeOpened(userID string, api *slack.Client) error {
+	user, err := api.GetUserInfo(userID)
+	if err != nil {
+		return err
 	}
+	return actions.PublishHome(api, user)
+}
+
+// inChannelActions Slash commands whose reply is shown to the whole channel, the rest are only shown to who typed them
+var inChannelActions = map[string]bool{
+	actions.Hello:    true,
+	actions.Price:    true,
+	actions.GetChart: true,
+	actions.DCA:      true,
+	actions.WhatIf:   true,
+}
+
+// handleSlashCommand Runs /crypto command like a mention. The reply goes through the response URL, so it also works
+// in DMs and in channels the bot hasn't joined
+func handleSlashCommand(slashCommand slack.SlashCommand, api *slack.Client) error {
+	text := slashCommand.Command + " " + slashCommand.Text
+	action, args := parseCommand(text)
 
+	var reply *response.Response
+	var err error
 	switch action {
+	case actions.Import:
+		reply = response.New("Slash commands can't carry files, upload the CSV and mention me with import in the same message", "I'm Sorry", response.Highlight, nil, "")
+	case actions.Alert:
+		crypto := ""
+		if len(args) > 0 {
+			crypto = args[0]
+		}
+		_, err = api.OpenView(slashCommand.TriggerID, actions.NewAlertModal(crypto, slashCommand.ChannelID))
+		if err != nil {
+			return fmt.Errorf("failed to open the alert modal: %w", err)
+		}
+		return nil
+	default:
+		uploadChannel := slashCommand.ChannelID
+		if action == actions.Export || action == actions.Gains {
+			// The bot can only upload files where it is, so they go to the user's DM with it
+			dm, _, _, err := api.OpenConversation(&slack.OpenConversationParameters{Users: []string{slashCommand.UserID}})
+			if err != nil {
+				return err
+			}
+			uploadChannel = dm.ID
+		}
+		reply, err = runCommand(command{
+			text:          text,
+			userID:        slashCommand.UserID,
+			channel:       slashCommand.ChannelID,
+			uploadChannel: uploadChannel,
+		}, api)
+		if err != nil || reply == nil {
+			return err
+		}
+	}
 
-	case actions.Hello:
-		attachment = actions.HandleHello(user, fields)
+	responseType := slack.ResponseTypeEphemeral
+	if inChannelActions[action] {
+		responseType = slack.ResponseTypeInChannel
+	}
+	err = slack.PostWebhook(slashCommand.ResponseURL, render.WebhookMessage(reply, responseType))
+	if err != nil {
+		return fmt.Errorf("failed to respond to the slash command: %w", err)
+	}
+	return nil
+}
 
-	case actions.Price:
-		attachment = actions.HandlePrice(splitedText, fields)
+// handleInteraction Handles the buttons of the replies and the alert modal. Command buttons run their command again and
+// replace the message with the new reply, so changing the range of a chart or refreshing a price doesn't flood the
+// channel
+func handleInteraction(callback slack.InteractionCallback, api *slack.Client) error {
+	switch callback.Type {
+	case slack.InteractionTypeViewSubmission:
+		if callback.View.CallbackID != actions.AlertModalCallback {
+			return nil
+		}
+		user, err := api.GetUserInfo(callback.User.ID)
+		if err != nil {
+			return err
+		}
+		return actions.SaveAlertModal(api, callback.View, user.ID, user.Name, utils.UserLocation(user.Name, user.TZ))
+	case slack.InteractionTypeBlockActions:
+	default:
+		return nil
+	}
 
-	case actions.Help:
-		attachment = actions.HandleHelp(fields)
+	for _, action := range callback.ActionCallback.BlockActions {
+		switch {
+		case strings.HasPrefix(action.ActionID, actions.CommandAction):
+			reply, err := runCommand(command{
+				text:          action.Value,
+				userID:        callback.User.ID,
+				channel:       callback.Channel.ID,
+				uploadChannel: callback.Channel.ID,
+			}, api)
+			if err != nil || reply == nil {
+				return err
+			}
+			message := render.WebhookMessage(reply, "")
+			message.ReplaceOriginal = true
+			// The response URL also reaches ephemeral replies, which can't be updated with chat.update
+			err = slack.PostWebhook(callback.ResponseURL, message)
+			if err != nil {
+				return fmt.Errorf("failed to respond to the button: %w", err)
+			}
+		case action.ActionID == actions.SetAlertAction:
+			_, err := api.OpenView(callback.TriggerID, actions.NewAlertModal(action.Value, callback.Channel.ID))
+			if err != nil {
+				return fmt.Errorf("failed to open the alert modal: %w", err)
+			}
+		case action.ActionID == actions.EditAlertAction:
+			user, err := api.GetUserInfo(callback.User.ID)
+			if err != nil {
+				return err
+			}
+			modal, err := actions.EditAlertModal(user.Name, action.Value, callback.Channel.ID, utils.UserLocation(user.Name, user.TZ))
+			if err != nil {
+				reply := response.New(err.Error(), "I'm Sorry", response.Error, nil, "")
+				return slack.PostWebhook(callback.ResponseURL, render.WebhookMessage(reply, slack.ResponseTypeEphemeral))
+			}
+			_, err = api.OpenView(callback.TriggerID, modal)
+			if err != nil {
+				return fmt.Errorf("failed to open the alert modal: %w", err)
+			}
+		case action.ActionID == actions.DeleteAlertAction:
+			user, err := api.GetUserInfo(callback.User.ID)
+			if err != nil {
+				return err
+			}
+			err = actions.HandleDeleteAlert(api, user, action.Value)
+			if err != nil {
+				return fmt.Errorf("failed to delete the alert: %w", err)
+			}
+		case action.ActionID == actions.LiveCancelAction:
+			err := actions.StopLive(action.Value, callback.User.ID)
+			if err != nil {
+				reply := response.New(err.Error(), "I'm Sorry", response.Highlight, nil, "")
+				return slack.PostWebhook(callback.ResponseURL, render.WebhookMessage(reply, slack.ResponseTypeEphemeral))
+			}
+		case action.ActionID == actions.AlertCryptoAction:
+			user, err := api.GetUserInfo(callback.User.ID)
+			if err != nil {
+				return err
+			}
+			modal := actions.RefreshAlertModal(callback.View, utils.UserLocation(user.Name, user.TZ))
+			_, err = api.UpdateView(modal, "", callback.View.Hash, callback.View.ID)
+			if err != nil {
+				return fmt.Errorf("failed to update the alert modal: %w", err)
+			}
+		}
+	}
+	return nil
+}
 
-	case actions.CryptoList:
-		attachment = actions.HandleCryptoList(fields)
+// validateSubmission Checks a submitted modal, returning the errors to show in it
+func validateSubmission(callback slack.InteractionCallback) (map[string]string, bool) {
+	if callback.View.CallbackID != actions.AlertModalCallback {
+		return nil, true
+	}
+	errs := actions.ValidateAlertModal(callback.View)
+	return errs, len(errs) == 0
+}
 
-	case actions.SetHigh:
-		attachment = actions.HandleSetLimit(splitedText, userName, "highLimit", fields)
+// command A request to the bot, from a mention or a slash command. Its text starts with the mention or the slash
+// command, followed by the action and its arguments
+type command struct {
+	text    string
+	userID  string
+	channel string
+	// uploadChannel Where files are uploaded, the bot can't upload to channels it hasn't joined
+	uploadChannel string
+	// timestamp Of the message that mentioned the bot, empty for slash commands
+	timestamp string
+	// messageThread Thread the message was written in, empty when it isn't a reply
+	messageThread string
+	// message The message with the command, when the event includes it
+	message *slack.Message
+	// thread Where the reply goes, empty to post it in the channel
+	thread string
+}
 
-	case actions.SetLow:
-		attachment = actions.HandleSetLimit(splitedText, userName, "lowLimit", fields)
+// runCommand Runs the command, returning the reply
+func runCommand(cmd command, api *slack.Client) (*response.Response, error) {
+	// Grab the user's name based on the ID of the one who mentioned the bot
+	user, err := api.GetUserInfo(cmd.userID)
+	if err != nil {
+		return nil, err
+	}
 
-	case actions.GetChart:
-		attachment = actions.HandleChart(splitedText, fields)
+	userName := user.Name
+	loc := utils.UserLocation(userName, user.TZ)
+	date := utils.GetFormattedActualDate(loc)
 
-	default:
-		text = fmt.Sprintf("How can I help you %s? Type 'help' after tagging me to know what I can do", user.Name)
-		pretext = "That's not a true command!"
-		color = "#3d3d3d"
-		attachment = utils.GetAttachment(text, pretext, color, fields, "")
+	// Add Some default context like user who mentioned the bot
+	fields := []response.Field{
+		{
+			Title: "Date",
+			Value: date,
+		}, {
+			Title: "Initializer",
+			Value: userName,
+		},
 	}
 
-	_, _, err = api.PostMessage(event.Channel, slack.MsgOptionAttachments(attachment))
+	tokens, err := commands.Tokenize(strings.ToLower(cmd.text))
 	if err != nil {
-		return fmt.Errorf("failed to post message: %w", err)
+		return response.New(err.Error(), "Command error", response.Error, fields, ""), nil
+	}
+	name, args, flags := commands.Parse(tokens, botMention)
+	command, found := commands.Lookup(name)
+	if !found {
+		text := fmt.Sprintf("How can I help you %s? Type 'help' after tagging me, or just 'help' in a direct message, to know what I can do", user.Name)
+		return response.New(text, "That's not a true command!", response.Muted, fields, ""), nil
+	}
+	values, err := command.Validate(args, flags)
+	if err != nil {
+		return response.New(utils.EscapeText(err.Error()), "Command error", response.Error, fields, ""), nil
 	}
 
-	return nil
+	return command.Handler(&commands.Context{
+		API:           api,
+		User:          user,
+		UserName:      userName,
+		Channel:       cmd.channel,
+		UploadChannel: cmd.uploadChannel,
+		Timestamp:     cmd.timestamp,
+		MessageThread: cmd.messageThread,
+		Message:       cmd.message,
+		Thread:        cmd.thread,
+		Location:      loc,
+		Fields:        fields,
+		Args:          args,
+		Flags:         flags,
+		Values:        values,
+	}), nil
+}
+
+// parseCommand Name of the command in the text and its arguments, or an empty name if there is none
+func parseCommand(text string) (string, []string) {
+	tokens, err := commands.Tokenize(strings.ToLower(text))
+	if err != nil {
+		return "", nil
+	}
+	name, args, _ := commands.Parse(tokens, botMention)
+	command, found := commands.Lookup(name)
+	if !found {
+		return "", nil
+	}
+	return command.Name, args
 }
@@ -0,0 +1,195 @@
+// Package render turns the bot responses into Slack Block Kit messages
+package render
+
+import (
+	"crypto-bot/response"
+	"fmt"
+	"github.com/slack-go/slack"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxHeaderLength Characters Slack allows in a header block
+	maxHeaderLength = 150
+	// maxSectionLength Characters Slack allows in the text of a section block
+	maxSectionLength = 3000
+	// maxMessageBlocks Blocks Slack allows in a message
+	maxMessageBlocks = 50
+	// maxViewBlocks Blocks Slack allows in a view, like the Home tab
+	maxViewBlocks = 100
+)
+
+// kindEmojis Emoji put before the title of the response
+var kindEmojis = map[response.Kind]string{
+	response.Success: ":white_check_mark: ",
+	response.Error:   ":x: ",
+}
+
+// Blocks Renders the response as header, text, sections, image, buttons and a context footer. Sections that don't
+// fit in a message are left out with a note
+func Blocks(r *response.Response) []slack.Block {
+	return limitedBlocks(r, maxMessageBlocks)
+}
+
+// limitedBlocks Renders the response in at most limit blocks. The image, buttons and footer are always kept, the
+// end of the text and sections is replaced by a note when they don't fit
+func limitedBlocks(r *response.Response, limit int) []slack.Block {
+	var blocks []slack.Block
+	if r.Title != "" {
+		title := truncate(kindEmojis[r.Kind]+r.Title, maxHeaderLength)
+		blocks = append(blocks, slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, title, true, false)))
+	}
+	blocks = append(blocks, textSections(r.Text)...)
+
+	for _, section := range r.Sections {
+		blocks = append(blocks, slack.NewDividerBlock())
+		text := section.Text
+		if section.Title != "" {
+			text = strings.TrimSpace(fmt.Sprintf("*%s*\n%s", section.Title, text))
+		}
+		blocks = append(blocks, textSections(text)...)
+		if len(section.Fields) > 0 {
+			blocks = append(blocks, slack.NewSectionBlock(nil, fieldObjects(section.Fields), nil))
+		}
+		for _, item := range section.Items {
+			var accessory *slack.Accessory
+			if item.Button != nil {
+				accessory = slack.NewAccessory(buttonElement(*item.Button))
+			}
+			text := slack.NewTextBlockObject(slack.MarkdownType, truncate(item.Text, maxSectionLength), false, false)
+			blocks = append(blocks, slack.NewSectionBlock(text, nil, accessory))
+		}
+	}
+
+	var tail []slack.Block
+	if r.Image != "" {
+		// Slack requires an alternative text for images
+		altText := r.Title
+		if altText == "" {
+			altText = "image"
+		}
+		tail = append(tail, slack.NewImageBlock(r.Image, altText, "", nil))
+	}
+	if len(r.Buttons) > 0 {
+		var elements []slack.BlockElement
+		for _, button := range r.Buttons {
+			elements = append(elements, buttonElement(button))
+		}
+		tail = append(tail, slack.NewActionBlock("", elements...))
+	}
+	if len(r.Footer) > 0 {
+		var elements []slack.MixedElement
+		for _, field := range r.Footer {
+			elements = append(elements, slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s:* %s", field.Title, field.Value), false, false))
+		}
+		tail = append(tail, slack.NewContextBlock("", elements...))
+	}
+
+	if len(blocks)+len(tail) > limit {
+		// One block is saved for the note
+		kept := limit - len(tail) - 1
+		for kept > 0 && blocks[kept-1].BlockType() == slack.MBTDivider {
+			kept--
+		}
+		note := slack.NewTextBlockObject(slack.MarkdownType, "_The rest doesn't fit in one message, try to narrow down what you asked for_", false, false)
+		blocks = append(blocks[:kept], slack.NewContextBlock("", note))
+	}
+	return append(blocks, tail...)
+}
+
+// Text Renders the response as plain text, used by notifications and clients that can't show blocks
+func Text(r *response.Response) string {
+	var parts []string
+	if r.Title != "" {
+		parts = append(parts, r.Title)
+	}
+	if r.Text != "" {
+		parts = append(parts, r.Text)
+	}
+	for _, section := range r.Sections {
+		if section.Title != "" {
+			parts = append(parts, section.Title)
+		}
+		if section.Text != "" {
+			parts = append(parts, section.Text)
+		}
+		for _, field := range section.Fields {
+			parts = append(parts, field.Title+": "+field.Value)
+		}
+		for _, item := range section.Items {
+			parts = append(parts, item.Text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// MsgOptions Options to post the response with chat.postMessage or chat.update
+func MsgOptions(r *response.Response) []slack.MsgOption {
+	return []slack.MsgOption{
+		slack.MsgOptionText(Text(r), false),
+		slack.MsgOptionBlocks(Blocks(r)...),
+	}
+}
+
+// WebhookMessage The response as a reply to a response URL, like the ones of slash commands
+func WebhookMessage(r *response.Response, responseType string) *slack.WebhookMessage {
+	return &slack.WebhookMessage{
+		ResponseType: responseType,
+		Text:         Text(r),
+		Blocks:       &slack.Blocks{BlockSet: Blocks(r)},
+	}
+}
+
+// HomeTab The response as the content of the Home tab of a user
+func HomeTab(r *response.Response) slack.HomeTabViewRequest {
+	return slack.HomeTabViewRequest{
+		Type:   slack.VTHomeTab,
+		Blocks: slack.Blocks{BlockSet: limitedBlocks(r, maxViewBlocks)},
+	}
+}
+
+// buttonElement Renders a button of the response
+func buttonElement(button response.Button) *slack.ButtonBlockElement {
+	element := slack.NewButtonBlockElement(button.ActionID, button.Value, slack.NewTextBlockObject(slack.PlainTextType, button.Text, true, false))
+	if button.Primary {
+		element.Style = slack.StylePrimary
+	}
+	return element
+}
+
+// textSections Splits the text in as many sections as needed to fit Slack's limit, breaking on lines
+func textSections(text string) []slack.Block {
+	var blocks []slack.Block
+	for text != "" {
+		chunk := text
+		if len(chunk) > maxSectionLength {
+			chunk = chunk[:maxSectionLength]
+			if i := strings.LastIndex(chunk, "\n"); i > 0 {
+				chunk = chunk[:i]
+			}
+			for !utf8.RuneStart(text[len(chunk)]) {
+				chunk = chunk[:len(chunk)-1]
+			}
+		}
+		text = strings.TrimPrefix(text[len(chunk):], "\n")
+		blocks = append(blocks, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, chunk, false, false), nil, nil))
+	}
+	return blocks
+}
+
+func fieldObjects(fields []response.Field) []*slack.TextBlockObject {
+	objects := make([]*slack.TextBlockObject, len(fields))
+	for i, field := range fields {
+		objects[i] = slack.NewTextBlockObject(slack.MarkdownType, fmt.Sprintf("*%s*\n%s", field.Title, field.Value), false, false)
+	}
+	return objects
+}
+
+func truncate(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) <= length {
+		return text
+	}
+	return string(runes[:length-1]) + "…"
+}
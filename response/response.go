@@ -0,0 +1,81 @@
+// Package response has the replies of the bot, independent of how they are sent. The render package turns them into
+// Slack messages
+package response
+
+// Kind What the reply is about, renderers use it to highlight errors and successes
+type Kind int
+
+const (
+	Info Kind = iota
+	Success
+	// Highlight Results the user asked for, like a price or a recorded trade
+	Highlight
+	// Muted Nothing to show, like an empty portfolio
+	Muted
+	Error
+)
+
+// Field A title and its value
+type Field struct {
+	Title string
+	Value string
+}
+
+// Section A part of the reply with an optional title, text, fields and items
+type Section struct {
+	Title  string
+	Text   string
+	Fields []Field
+	// Items Lines of the section that have an action each, like the alerts of a user with their delete button
+	Items []Item
+}
+
+// Item A line of a section, with an optional button next to it
+type Item struct {
+	Text   string
+	Button *Button
+}
+
+// Button An action the user can take on the reply
+type Button struct {
+	Text     string
+	ActionID string
+	Value    string
+	Primary  bool
+}
+
+// Response A reply of the bot
+type Response struct {
+	Kind     Kind
+	Title    string
+	Text     string
+	Sections []Section
+	// Image URL of an image shown under the text, like a chart
+	Image   string
+	Buttons []Button
+	// Footer Context of the reply, like its date and who asked for it
+	Footer []Field
+}
+
+// New Creates a reply with a title, a text, its footer and an optional image URL
+func New(text string, title string, kind Kind, footer []Field, image string) *Response {
+	return &Response{
+		Kind:   kind,
+		Title:  title,
+		Text:   text,
+		Image:  image,
+		Footer: footer,
+	}
+}
+
+// AddSection Appends a section to the reply
+func (r *Response) AddSection(section Section) *Response {
+	r.Sections = append(r.Sections, section)
+	return r
+}
+
+// AddButton Appends a button to the reply
+func (r *Response) AddButton(button Button) *Response {
+	r.Buttons = append(r.Buttons, button)
+	return r
+}
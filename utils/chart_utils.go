@@ -1,7 +1,8 @@
 package utils
 
 import (
-	"fmt"
+	"crypto-bot/chartjs"
+	"crypto-bot/downsample"
 	"math"
 	"strings"
 	"time"
@@ -10,37 +11,230 @@ import (
 const Layout = "02-01-2006"
 const MaxData = 250.0
 
-func BuildJSONDataFromData(data [][]float64, crypto string) (string, error) {
+// Colors used to tell apart each dataset when there is more than one on the same chart
+var seriesColors = []string{"#f7931a", "#627eea", "#14f195", "#0033ad", "#e6007a", "#ff007a", "#b6509e"}
 
-	var labels []string
-	var datasetValues []string
-	datasetLabel := crypto
+// AlignSeries Takes the timestamps of the shortest series and picks, for each one of them, the closest point of every series
+func AlignSeries(series [][][]float64) ([]float64, [][]float64) {
+	reference := 0
+	for i, s := range series {
+		if len(s) < len(series[reference]) {
+			reference = i
+		}
+	}
+
+	timestamps := make([]float64, len(series[reference]))
+	for i, point := range series[reference] {
+		timestamps[i] = point[0]
+	}
+
+	values := make([][]float64, len(series))
+	for i, s := range series {
+		values[i] = make([]float64, len(timestamps))
+		j := 0
+		for k, timestamp := range timestamps {
+			for j < len(s)-1 && math.Abs(s[j+1][0]-timestamp) <= math.Abs(s[j][0]-timestamp) {
+				j++
+			}
+			values[i][k] = s[j][1]
+		}
+	}
+	return timestamps, values
+}
+
+// NormalizeToPercentChange Expresses every value as its % change from the first one
+func NormalizeToPercentChange(values []float64) []float64 {
+	normalized := make([]float64, len(values))
+	if len(values) == 0 || values[0] == 0 {
+		return normalized
+	}
+	for i, v := range values {
+		normalized[i] = (v/values[0] - 1) * 100
+	}
+	return normalized
+}
+
+// PriceChartData Series to draw on a price chart, all of them at full resolution
+type PriceChartData struct {
+	Crypto string
+	// Location Timezone of the time axis labels
+	Location   *time.Location
+	Prices     [][]float64
+	Volumes    [][]float64
+	MarketCaps [][]float64
+	Overlays   []Overlay
+}
+
+// Overlay A line drawn over the price, with a value for each one of the prices
+type Overlay struct {
+	Label  string
+	Values []float64
+	Color  string
+	Dash   []float64
+	// Fill Chart.js fill option, like "-1" to fill the area up to the previous overlay
+	Fill interface{}
+}
+
+// BuildPriceChart Builds the line chart of the crypto price with its overlays. When volumes or market caps are given,
+// they are added as panels under the price sharing the time axis
+func BuildPriceChart(data PriceChartData, strategy downsample.Strategy) *chartjs.Config {
+
+	series := [][][]float64{data.Prices}
+	if len(data.Volumes) > 0 {
+		series = append(series, data.Volumes)
+	}
+	if len(data.MarketCaps) > 0 {
+		series = append(series, data.MarketCaps)
+	}
+	panels := len(series)
+	// Overlays share the price timestamps, they are aligned along with them but don't drive the downsampling
+	for _, overlay := range data.Overlays {
+		points := make([][]float64, len(data.Prices))
+		for i, price := range data.Prices {
+			points[i] = []float64{price[0], overlay.Values[i]}
+		}
+		series = append(series, points)
+	}
+	timestamps, values := AlignSeries(series)
+	timestamps, values = downsampleAligned(timestamps, values, panels, strategy)
+
+	config := chartjs.NewLineChart()
+	config.Data.Labels = displayLabels(timestamps, data.Location)
+	priceDataset := chartjs.NewLineDataset(data.Crypto, roundValues(values[0], 3), seriesColors[1])
+	priceDataset.YAxisID = "price"
+	config.AddDataset(priceDataset)
+	config.SetScale("price", &chartjs.Scale{Type: "linear", Position: "left"})
+
+	for i, overlay := range data.Overlays {
+		overlayDataset := chartjs.NewLineDataset(overlay.Label, roundValues(values[panels+i], 3), overlay.Color)
+		overlayDataset.YAxisID = "price"
+		overlayDataset.BorderWidth = 1
+		overlayDataset.BorderDash = overlay.Dash
+		if overlay.Fill != nil {
+			overlayDataset.Fill = overlay.Fill
+			overlayDataset.BackgroundColor = overlay.Color + "22"
+		}
+		config.AddDataset(overlayDataset)
+	}
 
-	long := len(data)
-	sampling := int(math.Ceil(float64(long) / MaxData))
+	if panels == 1 {
+		return config
+	}
+
+	config.Options.Scales["price"].Stack = "panels"
+	config.Options.Scales["price"].StackWeight = 3
+	config.Options.Scales["price"].Title = &chartjs.ScaleTitle{Display: true, Text: "Price"}
+	next := 1
+	if len(data.Volumes) > 0 {
+		volumeDataset := chartjs.NewBarDataset("Volume", roundValues(values[next], 0), seriesColors[0])
+		volumeDataset.YAxisID = "volume"
+		config.AddDataset(volumeDataset)
+		config.SetScale("volume", newPanelScale("Volume"))
+		next++
+	}
+	if len(data.MarketCaps) > 0 {
+		marketCapDataset := chartjs.NewLineDataset("Market cap", roundValues(values[next], 0), seriesColors[2])
+		marketCapDataset.YAxisID = "mcap"
+		config.AddDataset(marketCapDataset)
+		config.SetScale("mcap", newPanelScale("Market cap"))
+	}
+	return config
+}
+
+// BuildComparisonChart Builds a line chart with one dataset for each series sharing the timestamps
+func BuildComparisonChart(timestamps []float64, series [][]float64, names []string, yTitle string, loc *time.Location, strategy downsample.Strategy) *chartjs.Config {
+
+	timestamps, series = downsampleAligned(timestamps, series, len(series), strategy)
+
+	config := chartjs.NewLineChart()
+	config.Data.Labels = displayLabels(timestamps, loc)
+	for s, values := range series {
+		config.AddDataset(chartjs.NewLineDataset(names[s], roundValues(values, 3), seriesColors[s%len(seriesColors)]))
+	}
+	config.SetScale("y", &chartjs.Scale{Title: &chartjs.ScaleTitle{Display: true, Text: yTitle}})
+	return config
+}
+
+// BuildAllocationChart Builds a pie (or bar) chart with the weight in % of each label
+func BuildAllocationChart(labels []string, values []float64, chartType string) *chartjs.Config {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	weights := make([]float64, len(values))
+	colors := make([]string, len(values))
+	for i, v := range values {
+		if total != 0 {
+			weights[i] = v / total * 100
+		}
+		colors[i] = seriesColors[i%len(seriesColors)]
+	}
 
-	for i := 0; i < long; i += sampling {
-		labels = append(labels, getDisplayTime(time.Unix(int64(data[i][0]/1000), 0)))
-		datasetValues = append(datasetValues, fmt.Sprintf("%.3f", data[i][1]))
+	config := chartjs.NewConfig(chartType)
+	config.Data.Labels = labels
+	if chartType == "pie" {
+		config.AddDataset(chartjs.NewPieDataset("Allocation %", roundValues(weights, 2), colors))
+		return config
 	}
+	dataset := chartjs.NewBarDataset("Allocation %", roundValues(weights, 2), "")
+	dataset.BackgroundColor = colors
+	config.AddDataset(dataset)
+	config.Options.Plugins.Legend.Display = false
+	config.SetScale("y", &chartjs.Scale{Title: &chartjs.ScaleTitle{Display: true, Text: "% of the portfolio"}})
+	return config
+}
 
-	var sb strings.Builder
-	sb.WriteString("data:{labels:[")
-	for i, v := range labels {
-		if i != 0 {
-			sb.WriteString(",")
+func newPanelScale(title string) *chartjs.Scale {
+	return &chartjs.Scale{
+		Type:        "linear",
+		Position:    "left",
+		Stack:       "panels",
+		StackWeight: 1,
+		Offset:      true,
+		Title:       &chartjs.ScaleTitle{Display: true, Text: title},
+	}
+}
+
+func displayLabels(timestamps []float64, loc *time.Location) []string {
+	labels := make([]string, len(timestamps))
+	for i, timestamp := range timestamps {
+		labels[i] = getDisplayTime(time.Unix(int64(timestamp/1000), 0).In(loc))
+	}
+	return labels
+}
+
+func roundValues(values []float64, decimals int) []float64 {
+	pow := math.Pow(10, float64(decimals))
+	rounded := make([]float64, len(values))
+	for i, v := range values {
+		rounded[i] = math.Round(v*pow) / pow
+	}
+	return rounded
+}
+
+// downsampleAligned Downsamples series sharing the same timestamps, keeping the points each one of the first drivers series needs
+func downsampleAligned(timestamps []float64, series [][]float64, drivers int, strategy downsample.Strategy) ([]float64, [][]float64) {
+	points := make([][][]float64, drivers)
+	for s, values := range series[:drivers] {
+		points[s] = make([][]float64, len(values))
+		for i, v := range values {
+			points[s][i] = []float64{timestamps[i], v}
 		}
-		sb.WriteString(fmt.Sprintf("'%s'", v))
 	}
-	sb.WriteString(fmt.Sprintf("],datasets:[{fill:false,label:'%s',data:[", datasetLabel))
-	for i, v := range datasetValues {
-		if i != 0 {
-			sb.WriteString(",")
+	indices := downsample.MultiIndices(points, int(MaxData), strategy)
+
+	sampledTimestamps := make([]float64, len(indices))
+	sampledSeries := make([][]float64, len(series))
+	for s := range series {
+		sampledSeries[s] = make([]float64, len(indices))
+	}
+	for i, index := range indices {
+		sampledTimestamps[i] = timestamps[index]
+		for s := range series {
+			sampledSeries[s][i] = series[s][index]
 		}
-		sb.WriteString(fmt.Sprintf("'%s'", v))
 	}
-	sb.WriteString("]}]}")
-	return sb.String(), nil
+	return sampledTimestamps, sampledSeries
 }
 
 // Set to date format: 1 Jan 2000 00:00:00
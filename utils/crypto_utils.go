@@ -3,32 +3,35 @@ package utils
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"os"
+	"time"
 )
 
 type ResponseCEX struct {
 	LastPrice string `json:"lprice"`
 	Currency1 string `json:"curr1"`
 	Currency2 string `json:"curr2"`
+	Error     string `json:"error"`
 }
 
-func GetCryptoValue(crypto string, currency string) string {
-	response, err := http.Get("https://cex.io/api/last_price/" + crypto + "/" + currency)
+// priceClient Gets the prices, the rules loop and the live prices mustn't hang on a slow price provider
+var priceClient = &http.Client{Timeout: 10 * time.Second}
 
+// GetCryptoValue Gets the last price of the crypto in the currency from the price provider
+func GetCryptoValue(crypto string, currency string) (string, error) {
+	response, err := priceClient.Get("https://cex.io/api/last_price/" + crypto + "/" + currency)
 	if err != nil {
-		fmt.Print(err.Error())
-		os.Exit(1)
+		return "", err
 	}
+	defer response.Body.Close()
 
 	var JsonResponse ResponseCEX
-	body, _ := ioutil.ReadAll(response.Body)
-	err = json.Unmarshal(body, &JsonResponse)
-
+	err = json.NewDecoder(response.Body).Decode(&JsonResponse)
 	if err != nil {
-		fmt.Print(err.Error())
-		os.Exit(1)
+		return "", err
+	}
+	if JsonResponse.LastPrice == "" {
+		return "", fmt.Errorf("no price for %s/%s: %s", crypto, currency, JsonResponse.Error)
 	}
-	return JsonResponse.LastPrice
+	return JsonResponse.LastPrice, nil
 }
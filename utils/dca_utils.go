@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+)
+
+// frequencies Time between the buys of a DCA plan
+var frequencies = map[string]func(time.Time) time.Time{
+	"daily":    func(t time.Time) time.Time { return t.AddDate(0, 0, 1) },
+	"weekly":   func(t time.Time) time.Time { return t.AddDate(0, 0, 7) },
+	"biweekly": func(t time.Time) time.Time { return t.AddDate(0, 0, 14) },
+	"monthly":  func(t time.Time) time.Time { return t.AddDate(0, 1, 0) },
+}
+
+// Simulation Outcome of investing in a crypto on some dates. The series have one value per price point
+type Simulation struct {
+	Buys int
+	// Skipped Buys dated after the last price, they weren't made
+	Skipped  int
+	Invested float64
+	Units    float64
+	Value    float64
+	// MaxDrawdown Biggest fall in % of the value per invested USD from a previous high, so new contributions
+	// don't hide the falls
+	MaxDrawdown    float64
+	Timestamps     []float64
+	InvestedSeries []float64
+	ValueSeries    []float64
+}
+
+// Return Gain in % over what was invested
+func (s *Simulation) Return() float64 {
+	if s.Invested == 0 {
+		return 0
+	}
+	return (s.Value - s.Invested) / s.Invested * 100
+}
+
+// AverageCost Price paid on average for each unit
+func (s *Simulation) AverageCost() float64 {
+	if s.Units == 0 {
+		return 0
+	}
+	return s.Invested / s.Units
+}
+
+// BuyDates Dates of the buys of a DCA plan between from and to, with daily, weekly, biweekly or monthly frequency
+func BuyDates(frequency string, from time.Time, to time.Time) ([]time.Time, error) {
+	next, found := frequencies[frequency]
+	if !found {
+		return nil, fmt.Errorf("%q is not a frequency I know, use daily, weekly, biweekly or monthly", frequency)
+	}
+	var dates []time.Time
+	for date := from; !date.After(to); date = next(date) {
+		dates = append(dates, date)
+	}
+	return dates, nil
+}
+
+// Simulate Buys amount USD of the crypto at the first price (unix ms, price pairs) on or after each date, and follows
+// the value of what was bought until the last price. Dates after the last price are counted as skipped
+func Simulate(prices [][]float64, amount float64, dates []time.Time) (*Simulation, error) {
+	if len(prices) == 0 {
+		return nil, fmt.Errorf("there are no prices to simulate with")
+	}
+	simulation := &Simulation{
+		Timestamps:     make([]float64, len(prices)),
+		InvestedSeries: make([]float64, len(prices)),
+		ValueSeries:    make([]float64, len(prices)),
+	}
+	next, peak := 0, 0.0
+	for i, point := range prices {
+		timestamp, price := point[0], point[1]
+		for next < len(dates) && float64(dates[next].UnixNano()/int64(time.Millisecond)) <= timestamp {
+			simulation.Units += amount / price
+			simulation.Invested += amount
+			simulation.Buys++
+			next++
+		}
+		value := simulation.Units * price
+		if simulation.Invested > 0 {
+			perInvested := value / simulation.Invested
+			if perInvested > peak {
+				peak = perInvested
+			} else if peak > 0 && (peak-perInvested)/peak*100 > simulation.MaxDrawdown {
+				simulation.MaxDrawdown = (peak - perInvested) / peak * 100
+			}
+		}
+		simulation.Timestamps[i] = timestamp
+		simulation.InvestedSeries[i] = simulation.Invested
+		simulation.ValueSeries[i] = value
+	}
+	if simulation.Buys == 0 {
+		return nil, fmt.Errorf("there are no prices after the first buy date")
+	}
+	simulation.Skipped = len(dates) - next
+	simulation.Value = simulation.ValueSeries[len(prices)-1]
+	return simulation, nil
+}
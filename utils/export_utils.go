@@ -0,0 +1,109 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportRow One point of the market chart, as written in the exported files
+type ExportRow struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Price       float64   `json:"price"`
+	MarketCap   float64   `json:"market_cap"`
+	TotalVolume float64   `json:"total_volume"`
+}
+
+// BuildExportRows Joins the market chart series in rows, one per timestamp of the shortest series (see AlignSeries),
+// stamped in UTC
+func BuildExportRows(prices [][]float64, marketCaps [][]float64, volumes [][]float64) []ExportRow {
+	if len(prices) == 0 {
+		return nil
+	}
+	series := [][][]float64{prices}
+	if len(marketCaps) > 0 {
+		series = append(series, marketCaps)
+	}
+	if len(volumes) > 0 {
+		series = append(series, volumes)
+	}
+	timestamps, values := AlignSeries(series)
+
+	rows := make([]ExportRow, len(timestamps))
+	for i, timestamp := range timestamps {
+		rows[i].Timestamp = time.Unix(0, int64(timestamp)*int64(time.Millisecond)).UTC()
+		rows[i].Price = values[0][i]
+		next := 1
+		if len(marketCaps) > 0 {
+			rows[i].MarketCap = values[next][i]
+			next++
+		}
+		if len(volumes) > 0 {
+			rows[i].TotalVolume = values[next][i]
+		}
+	}
+	return rows
+}
+
+// ResampleRows Keeps one row per interval, the last one of each (like a candle close), stamped at the interval start.
+// The intervals are aligned to the wall clock of loc, so daily rows start at the user's midnight
+func ResampleRows(rows []ExportRow, interval time.Duration, loc *time.Location) []ExportRow {
+	if interval <= 0 {
+		return rows
+	}
+	var resampled []ExportRow
+	for _, row := range rows {
+		bucket := truncateIn(row.Timestamp, interval, loc)
+		row.Timestamp = bucket
+		if len(resampled) > 0 && resampled[len(resampled)-1].Timestamp.Equal(bucket) {
+			resampled[len(resampled)-1] = row
+		} else {
+			resampled = append(resampled, row)
+		}
+	}
+	return resampled
+}
+
+// truncateIn Rounds t down to a multiple of interval on the wall clock of loc, returned in UTC
+func truncateIn(t time.Time, interval time.Duration, loc *time.Location) time.Time {
+	_, offset := t.In(loc).Zone()
+	wall := t.UTC().Add(time.Duration(offset) * time.Second).Truncate(interval)
+	// Read the wall clock back in loc, so a DST change between t and the start of its interval is taken into account
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc).UTC()
+}
+
+// ExportCSV Writes the rows as CSV with a header line
+func ExportCSV(rows []ExportRow, currency string) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	currency = strings.ToLower(currency)
+	err := writer.Write([]string{"timestamp", "price_" + currency, "market_cap_" + currency, "total_volume_" + currency})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		err = writer.Write([]string{
+			row.Timestamp.Format(time.RFC3339),
+			strconv.FormatFloat(row.Price, 'f', -1, 64),
+			strconv.FormatFloat(row.MarketCap, 'f', -1, 64),
+			strconv.FormatFloat(row.TotalVolume, 'f', -1, 64),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// ExportJSON Writes the rows as a JSON document
+func ExportJSON(rows []ExportRow, crypto string, currency string) ([]byte, error) {
+	return json.MarshalIndent(struct {
+		Crypto   string      `json:"crypto"`
+		Currency string      `json:"currency"`
+		Data     []ExportRow `json:"data"`
+	}{crypto, strings.ToLower(currency), rows}, "", "  ")
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResampleRowsInLocation(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	if err != nil {
+		t.Skip("no timezone database:", err)
+	}
+	hourly := func(from time.Time, hours int) []ExportRow {
+		rows := make([]ExportRow, hours)
+		for i := range rows {
+			rows[i] = ExportRow{Timestamp: from.Add(time.Duration(i) * time.Hour).UTC(), Price: float64(i)}
+		}
+		return rows
+	}
+
+	tests := []struct {
+		name     string
+		rows     []ExportRow
+		interval time.Duration
+		loc      *time.Location
+		want     []time.Time
+	}{
+		{
+			name:     "days start at midnight UTC",
+			rows:     hourly(time.Date(2022, time.May, 9, 20, 0, 0, 0, time.UTC), 6),
+			interval: 24 * time.Hour,
+			loc:      time.UTC,
+			want:     []time.Time{time.Date(2022, time.May, 9, 0, 0, 0, 0, time.UTC), time.Date(2022, time.May, 10, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:     "days start at the user's midnight",
+			rows:     hourly(time.Date(2022, time.May, 9, 20, 0, 0, 0, time.UTC), 6),
+			interval: 24 * time.Hour,
+			loc:      madrid,
+			want:     []time.Time{time.Date(2022, time.May, 9, 0, 0, 0, 0, madrid), time.Date(2022, time.May, 10, 0, 0, 0, 0, madrid)},
+		},
+		{
+			name:     "the day of a DST change starts at its own midnight",
+			rows:     hourly(time.Date(2022, time.March, 27, 8, 0, 0, 0, time.UTC), 1),
+			interval: 24 * time.Hour,
+			loc:      madrid,
+			want:     []time.Time{time.Date(2022, time.March, 27, 0, 0, 0, 0, madrid)},
+		},
+		{
+			name:     "hours follow the half hour offsets",
+			rows:     hourly(time.Date(2022, time.May, 9, 20, 15, 0, 0, time.UTC), 2),
+			interval: time.Hour,
+			loc:      time.FixedZone("IST", 5*3600+1800),
+			want:     []time.Time{time.Date(2022, time.May, 9, 19, 30, 0, 0, time.UTC), time.Date(2022, time.May, 9, 20, 30, 0, 0, time.UTC)},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resampled := ResampleRows(test.rows, test.interval, test.loc)
+			if len(resampled) != len(test.want) {
+				t.Fatalf("got %d rows, want %d: %v", len(resampled), len(test.want), resampled)
+			}
+			for i, row := range resampled {
+				if !row.Timestamp.Equal(test.want[i]) {
+					t.Errorf("row %d starts at %v, want %v", i, row.Timestamp, test.want[i].UTC())
+				}
+			}
+			// Each bucket keeps its last row, like a candle close
+			if last := resampled[len(resampled)-1]; last.Price != test.rows[len(test.rows)-1].Price {
+				t.Errorf("the last bucket has the price %v, want %v", last.Price, test.rows[len(test.rows)-1].Price)
+			}
+		})
+	}
+}
@@ -1,30 +1,13 @@
 package utils
 
 import (
-	"fmt"
-	"github.com/slack-go/slack"
+	"sort"
 	"strings"
 	"time"
 )
 
-func GetFormattedActualDate() string {
-	t := time.Now()
-	return fmt.Sprintf("%d-%02d-%02d %02d:%02d:%02d",
-		t.Year(), t.Month(), t.Day(),
-		t.Hour(), t.Minute(), t.Second())
-}
-
-func GetAttachment(text string, pretext string, color string, fields []slack.AttachmentField, image string) slack.Attachment {
-	attachment := slack.Attachment{}
-	attachment.Fields = fields
-	attachment.Text = text
-	attachment.Pretext = pretext
-	attachment.Color = color
-	if image != "" {
-		attachment.ImageURL = image
-	}
-
-	return attachment
+func GetFormattedActualDate(loc *time.Location) string {
+	return FormatDate(time.Now(), loc)
 }
 
 func GetFullCryptoName(cryptoName string) (string, bool) {
@@ -50,3 +33,18 @@ func GetAbbreviatedCryptoName(cryptoName string) (string, bool) {
 		return cryptoName, found
 	}
 }
+
+// SupportedCryptos Abbreviations of the cryptos I know, sorted
+func SupportedCryptos() []string {
+	var cryptos []string
+	for abbreviation := range abbreviatedToFullMap {
+		cryptos = append(cryptos, abbreviation)
+	}
+	sort.Strings(cryptos)
+	return cryptos
+}
+
+// EscapeText Escapes the characters Slack reads as markup, like the <> of <crypto> in a usage line
+func EscapeText(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
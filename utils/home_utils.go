@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+)
+
+const defaultHomeUsersFileName = "home_users.txt"
+
+// homeUsersMutex Guards the home users file, written when a Home tab is opened and read when one is refreshed
+var homeUsersMutex sync.Mutex
+
+// SaveHomeUser Remembers that the user opened the Home tab, so it is refreshed when their alerts change, even after a
+// restart. The rules only keep the user name, and the Home tab is published to the user ID
+func SaveHomeUser(userName string, userID string) error {
+	homeUsersMutex.Lock()
+	defer homeUsersMutex.Unlock()
+	users, err := loadHomeUsers()
+	if err != nil {
+		return err
+	}
+	if users[userName] == userID {
+		return nil
+	}
+	users[userName] = userID
+	return saveHomeUsers(users)
+}
+
+// GetHomeUser Gets the ID of the user if they opened the Home tab
+func GetHomeUser(userName string) (string, bool) {
+	homeUsersMutex.Lock()
+	defer homeUsersMutex.Unlock()
+	users, err := loadHomeUsers()
+	if err != nil {
+		return "", false
+	}
+	userID, found := users[userName]
+	return userID, found
+}
+
+func homeUsersFileName() string {
+	if name := os.Getenv("HOME_USERS_FILENAME"); name != "" {
+		return name
+	}
+	return defaultHomeUsersFileName
+}
+
+// loadHomeUsers Reads the user|ID lines of the home users file
+func loadHomeUsers() (map[string]string, error) {
+	users := make(map[string]string)
+	usersFile, err := os.Open(homeUsersFileName())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return users, nil
+		}
+		return nil, err
+	}
+	defer usersFile.Close()
+
+	scanner := bufio.NewScanner(usersFile)
+	for scanner.Scan() {
+		data := strings.Split(scanner.Text(), "|")
+		if len(data) == 2 {
+			users[data[0]] = data[1]
+		}
+	}
+	return users, scanner.Err()
+}
+
+func saveHomeUsers(users map[string]string) error {
+	var sb strings.Builder
+	for userName, userID := range users {
+		sb.WriteString(userName + "|" + userID + "\n")
+	}
+	return ioutil.WriteFile(homeUsersFileName(), []byte(sb.String()), 0644)
+}
@@ -0,0 +1,381 @@
+package utils
+
+import (
+	"bufio"
+	"crypto-bot/decimal"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultPaperFileName = "paper.txt"
+
+// PaperStartingBalance Virtual USD every paper trading account starts with
+var PaperStartingBalance = decimal.New(10000)
+
+const (
+	MarketOrder = "market"
+	LimitOrder  = "limit"
+	StopOrder   = "stop"
+)
+
+const (
+	OrderOpen      = "open"
+	OrderFilled    = "filled"
+	OrderCancelled = "cancelled"
+	OrderRejected  = "rejected"
+)
+
+// paperMutex Guards the paper orders file, which is written by the commands and by the rules loop
+var paperMutex sync.Mutex
+
+// PaperOrder An order of the paper trading simulator. Limit and stop orders stay open until the price crosses Price
+type PaperOrder struct {
+	ID       int
+	User     string
+	Channel  string
+	Date     time.Time
+	Side     string
+	Type     string
+	Crypto   string
+	Quantity decimal.Decimal
+	// Price Limit or stop price, zero for market orders
+	Price     decimal.Decimal
+	Status    string
+	FilledAt  time.Time
+	FillPrice decimal.Decimal
+}
+
+// Triggered Tells if the order must be filled at that price: limits fill at their price or better, stops once the
+// price goes through them
+func (o *PaperOrder) Triggered(price decimal.Decimal) bool {
+	switch o.Type {
+	case LimitOrder:
+		if o.Side == Buy {
+			return !price.GreaterThan(o.Price)
+		}
+		return !price.LessThan(o.Price)
+	case StopOrder:
+		if o.Side == Buy {
+			return !price.LessThan(o.Price)
+		}
+		return !price.GreaterThan(o.Price)
+	}
+	return true
+}
+
+// PaperAccount Cash and positions of a user, from the orders filled so far
+type PaperAccount struct {
+	User     string
+	Cash     decimal.Decimal
+	Holdings []*Holding
+}
+
+func paperFileName() string {
+	if name := os.Getenv("PAPER_FILENAME"); name != "" {
+		return name
+	}
+	return defaultPaperFileName
+}
+
+// LoadPaperOrders Reads the orders of the user (or of everyone when user is empty) in the order they were placed
+func LoadPaperOrders(user string) ([]*PaperOrder, error) {
+	paperMutex.Lock()
+	defer paperMutex.Unlock()
+	orders, err := loadPaperOrders()
+	if err != nil || user == "" {
+		return orders, err
+	}
+	return ordersOf(orders, user), nil
+}
+
+// ComputePaperAccount Replays the filled orders of a user to get the cash left and the positions
+func ComputePaperAccount(user string, orders []*PaperOrder) (*PaperAccount, error) {
+	account := &PaperAccount{User: user, Cash: PaperStartingBalance}
+	var transactions []Transaction
+	for _, order := range orders {
+		if order.User != user || order.Status != OrderFilled {
+			continue
+		}
+		total := order.Quantity.Mul(order.FillPrice)
+		if order.Side == Buy {
+			account.Cash = account.Cash.Sub(total)
+		} else {
+			account.Cash = account.Cash.Add(total)
+		}
+		transactions = append(transactions, order.transaction())
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Date.Before(transactions[j].Date)
+	})
+	holdings, err := ComputeHoldings(transactions)
+	if err != nil {
+		return nil, err
+	}
+	account.Holdings = holdings
+	return account, nil
+}
+
+// PlacePaperOrder Saves the order with a new ID. Market orders are filled right away at marketPrice, the rest stay
+// open. It fails when the account can't pay for the order or doesn't hold what it sells
+func PlacePaperOrder(order PaperOrder, marketPrice decimal.Decimal) (*PaperOrder, error) {
+	paperMutex.Lock()
+	defer paperMutex.Unlock()
+
+	orders, err := loadPaperOrders()
+	if err != nil {
+		return nil, err
+	}
+	account, err := ComputePaperAccount(order.User, orders)
+	if err != nil {
+		return nil, err
+	}
+
+	order.ID = 1
+	for _, existing := range orders {
+		if existing.ID >= order.ID {
+			order.ID = existing.ID + 1
+		}
+	}
+	price := order.Price
+	if order.Type == MarketOrder {
+		price = marketPrice
+	}
+	err = account.canAfford(&order, price)
+	if err != nil {
+		return nil, err
+	}
+	if order.Type == MarketOrder {
+		order.Status = OrderFilled
+		order.FilledAt = order.Date
+		order.FillPrice = marketPrice
+	} else {
+		order.Status = OrderOpen
+	}
+	return &order, savePaperOrders(append(orders, &order))
+}
+
+// CancelPaperOrder Cancels an open order of the user
+func CancelPaperOrder(user string, id int) error {
+	paperMutex.Lock()
+	defer paperMutex.Unlock()
+
+	orders, err := loadPaperOrders()
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if order.ID == id && order.User == user {
+			if order.Status != OrderOpen {
+				return fmt.Errorf("order #%d is already %s", id, order.Status)
+			}
+			order.Status = OrderCancelled
+			return savePaperOrders(orders)
+		}
+	}
+	return fmt.Errorf("you don't have an order #%d", id)
+}
+
+// ResetPaperAccount Deletes every order of the user, so the account starts again with PaperStartingBalance
+func ResetPaperAccount(user string) error {
+	paperMutex.Lock()
+	defer paperMutex.Unlock()
+
+	orders, err := loadPaperOrders()
+	if err != nil {
+		return err
+	}
+	var kept []*PaperOrder
+	for _, order := range orders {
+		if order.User != user {
+			kept = append(kept, order)
+		}
+	}
+	return savePaperOrders(kept)
+}
+
+// ProcessPaperOrders Fills the open orders whose price was crossed, or rejects them if the account can't pay for them
+// anymore, returning the orders that changed. prices are fetched before, so the orders file isn't locked while the
+// price provider answers. Orders of cryptos without a price stay open
+func ProcessPaperOrders(prices map[string]decimal.Decimal) ([]*PaperOrder, error) {
+	paperMutex.Lock()
+	defer paperMutex.Unlock()
+
+	orders, err := loadPaperOrders()
+	if err != nil {
+		return nil, err
+	}
+	var changed []*PaperOrder
+	for _, order := range orders {
+		if order.Status != OrderOpen {
+			continue
+		}
+		price, found := prices[order.Crypto]
+		if !found || !order.Triggered(price) {
+			continue
+		}
+		account, err := ComputePaperAccount(order.User, orders)
+		if err != nil {
+			return nil, err
+		}
+		if account.canAfford(order, price) != nil {
+			order.Status = OrderRejected
+		} else {
+			order.Status = OrderFilled
+			order.FilledAt = time.Now()
+			order.FillPrice = price
+		}
+		changed = append(changed, order)
+	}
+	if len(changed) == 0 {
+		return nil, nil
+	}
+	return changed, savePaperOrders(orders)
+}
+
+// canAfford Checks that the account has the cash to buy, or the units to sell, at that price
+func (a *PaperAccount) canAfford(order *PaperOrder, price decimal.Decimal) error {
+	if order.Side == Buy {
+		total := order.Quantity.Mul(price)
+		if total.GreaterThan(a.Cash) {
+			return fmt.Errorf("you need %s USD but only have %s USD", total.StringFixed(2), a.Cash.StringFixed(2))
+		}
+		return nil
+	}
+	held := decimal.Zero
+	for _, holding := range a.Holdings {
+		if holding.Crypto == order.Crypto {
+			held = holding.Quantity
+		}
+	}
+	if order.Quantity.GreaterThan(held) {
+		return fmt.Errorf("you can't sell %s %s, you only have %s", order.Quantity, order.Crypto, held)
+	}
+	return nil
+}
+
+// transaction The fill of the order as a portfolio transaction
+func (o *PaperOrder) transaction() Transaction {
+	return Transaction{
+		User:     o.User,
+		Date:     o.FilledAt,
+		Side:     o.Side,
+		Crypto:   o.Crypto,
+		Quantity: o.Quantity,
+		Price:    o.FillPrice,
+	}
+}
+
+func ordersOf(orders []*PaperOrder, user string) []*PaperOrder {
+	var userOrders []*PaperOrder
+	for _, order := range orders {
+		if order.User == user {
+			userOrders = append(userOrders, order)
+		}
+	}
+	return userOrders
+}
+
+// loadPaperOrders Reads the id|user|channel|date|side|type|crypto|quantity|price|status|filledAt|fillPrice lines of
+// the paper orders file
+func loadPaperOrders() ([]*PaperOrder, error) {
+	paperFile, err := os.Open(paperFileName())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer paperFile.Close()
+
+	var orders []*PaperOrder
+	scanner := bufio.NewScanner(paperFile)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		order, err := parsePaperOrder(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+	return orders, scanner.Err()
+}
+
+func parsePaperOrder(line string) (*PaperOrder, error) {
+	data := strings.Split(line, "|")
+	if len(data) != 12 {
+		return nil, fmt.Errorf("Error parsing the paper order")
+	}
+	id, err := strconv.Atoi(data[0])
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the paper order id")
+	}
+	date, err := time.Parse(time.RFC3339, data[3])
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the paper order date")
+	}
+	quantity, err := decimal.NewFromString(data[7])
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the paper order quantity")
+	}
+	price, err := decimal.NewFromString(data[8])
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing the paper order price")
+	}
+	order := &PaperOrder{
+		ID:       id,
+		User:     data[1],
+		Channel:  data[2],
+		Date:     date,
+		Side:     data[4],
+		Type:     data[5],
+		Crypto:   data[6],
+		Quantity: quantity,
+		Price:    price,
+		Status:   data[9],
+	}
+	if order.Status == OrderFilled {
+		order.FilledAt, err = time.Parse(time.RFC3339, data[10])
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing the paper order fill date")
+		}
+		order.FillPrice, err = decimal.NewFromString(data[11])
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing the paper order fill price")
+		}
+	}
+	return order, nil
+}
+
+func savePaperOrders(orders []*PaperOrder) error {
+	var sb strings.Builder
+	for _, order := range orders {
+		filledAt, fillPrice := "", ""
+		if order.Status == OrderFilled {
+			filledAt = order.FilledAt.UTC().Format(time.RFC3339)
+			fillPrice = order.FillPrice.String()
+		}
+		sb.WriteString(strings.Join([]string{
+			strconv.Itoa(order.ID),
+			order.User,
+			order.Channel,
+			order.Date.UTC().Format(time.RFC3339),
+			order.Side,
+			order.Type,
+			order.Crypto,
+			order.Quantity.String(),
+			order.Price.String(),
+			order.Status,
+			filledAt,
+			fillPrice,
+		}, "|") + "\n")
+	}
+	return ioutil.WriteFile(paperFileName(), []byte(sb.String()), 0644)
+}
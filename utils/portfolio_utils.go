@@ -0,0 +1,312 @@
+package utils
+
+import (
+	"bufio"
+	"crypto-bot/decimal"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultPortfolioFileName = "portfolio.txt"
+
+const (
+	Buy  = "buy"
+	Sell = "sell"
+)
+
+// portfolioMutex Guards the transactions file
+var portfolioMutex sync.Mutex
+
+// Transaction A buy or sell of a crypto made by a user, priced in USD
+type Transaction struct {
+	User     string
+	Date     time.Time
+	Side     string
+	Crypto   string
+	Quantity decimal.Decimal
+	Price    decimal.Decimal
+	// Ref Identifies imported transactions (like coinbase:<id>), empty for the ones entered by hand
+	Ref string
+}
+
+// Holding Position of a user in one crypto, using the average cost method
+type Holding struct {
+	Crypto   string
+	Quantity decimal.Decimal
+	// CostBasis What the current quantity cost
+	CostBasis decimal.Decimal
+	// RealizedPnL Profit or loss of everything sold so far
+	RealizedPnL decimal.Decimal
+}
+
+// AverageCost Cost basis of each unit held
+func (h *Holding) AverageCost() decimal.Decimal {
+	if h.Quantity.IsZero() {
+		return decimal.Zero
+	}
+	return h.CostBasis.Div(h.Quantity)
+}
+
+func PortfolioFileName() string {
+	if name := os.Getenv("PORTFOLIO_FILENAME"); name != "" {
+		return name
+	}
+	return defaultPortfolioFileName
+}
+
+// LoadTransactions Reads the transactions of the user (or of everyone when user is empty) in the order they were made
+func LoadTransactions(user string) ([]Transaction, error) {
+	portfolioMutex.Lock()
+	defer portfolioMutex.Unlock()
+	return loadTransactions(user)
+}
+
+func loadTransactions(user string) ([]Transaction, error) {
+	portfolioFile, err := os.Open(PortfolioFileName())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer portfolioFile.Close()
+
+	var transactions []Transaction
+	scanner := bufio.NewScanner(portfolioFile)
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+		transaction, err := parseTransaction(scanner.Text())
+		if err != nil {
+			return nil, err
+		}
+		if user == "" || transaction.User == user {
+			transactions = append(transactions, transaction)
+		}
+	}
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].Date.Before(transactions[j].Date)
+	})
+	return transactions, scanner.Err()
+}
+
+// SaveTransaction Appends the transaction, checking first that a sell doesn't leave a negative quantity
+func SaveTransaction(transaction Transaction) error {
+	portfolioMutex.Lock()
+	defer portfolioMutex.Unlock()
+
+	if transaction.Side == Sell {
+		transactions, err := loadTransactions(transaction.User)
+		if err != nil {
+			return err
+		}
+		_, err = ComputeHoldings(append(transactions, transaction))
+		if err != nil {
+			return err
+		}
+	}
+
+	portfolioFile, err := os.OpenFile(PortfolioFileName(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer portfolioFile.Close()
+	_, err = portfolioFile.WriteString(formatTransaction(transaction) + "\n")
+	return err
+}
+
+// ImportTransactions Saves the transactions that weren't imported before (by their Ref), returning how many were
+// saved and how many were duplicates. Nothing is saved if any transaction has a quantity that isn't positive or a
+// negative price, or if any sell ends up bigger than the quantity held
+func ImportTransactions(user string, transactions []Transaction) (int, int, error) {
+	for _, transaction := range transactions {
+		if transaction.Quantity.Sign() <= 0 {
+			return 0, 0, fmt.Errorf("the %s of %s on %s has a quantity of %s, it must be positive",
+				transaction.Side, transaction.Crypto, transaction.Date.Format("2006-01-02"), transaction.Quantity)
+		}
+		if transaction.Price.Sign() < 0 {
+			return 0, 0, fmt.Errorf("the %s of %s on %s has a negative price of %s",
+				transaction.Side, transaction.Crypto, transaction.Date.Format("2006-01-02"), transaction.Price)
+		}
+	}
+
+	portfolioMutex.Lock()
+	defer portfolioMutex.Unlock()
+
+	existing, err := loadTransactions(user)
+	if err != nil {
+		return 0, 0, err
+	}
+	refs := make(map[string]bool)
+	for _, transaction := range existing {
+		if transaction.Ref != "" {
+			refs[transaction.Ref] = true
+		}
+	}
+
+	var newTransactions []Transaction
+	duplicates := 0
+	for _, transaction := range transactions {
+		if refs[transaction.Ref] {
+			duplicates++
+			continue
+		}
+		refs[transaction.Ref] = true
+		newTransactions = append(newTransactions, transaction)
+	}
+	if len(newTransactions) == 0 {
+		return 0, duplicates, nil
+	}
+
+	all := append(existing, newTransactions...)
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Date.Before(all[j].Date)
+	})
+	_, err = ComputeHoldings(all)
+	if err != nil {
+		return 0, duplicates, err
+	}
+
+	portfolioFile, err := os.OpenFile(PortfolioFileName(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return 0, duplicates, err
+	}
+	defer portfolioFile.Close()
+	var sb strings.Builder
+	for _, transaction := range newTransactions {
+		sb.WriteString(formatTransaction(transaction) + "\n")
+	}
+	_, err = portfolioFile.WriteString(sb.String())
+	if err != nil {
+		return 0, duplicates, err
+	}
+	return len(newTransactions), duplicates, nil
+}
+
+// ComputeHoldings Replays the transactions (sorted by date) to get the holdings, sorted by crypto name.
+// It fails when a sell is bigger than the quantity held at that moment
+func ComputeHoldings(transactions []Transaction) ([]*Holding, error) {
+	holdingsMap := make(map[string]*Holding)
+	for _, transaction := range transactions {
+		err := applyTransaction(holdingsMap, transaction)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	holdings := make([]*Holding, 0, len(holdingsMap))
+	for _, holding := range holdingsMap {
+		holdings = append(holdings, holding)
+	}
+	sort.Slice(holdings, func(i, j int) bool {
+		return holdings[i].Crypto < holdings[j].Crypto
+	})
+	return holdings, nil
+}
+
+// PortfolioValueSeries Value of the holdings and their cost basis at each timestamp (unix ms), given the aligned
+// prices of each crypto at those timestamps
+func PortfolioValueSeries(transactions []Transaction, cryptos []string, timestamps []float64, prices [][]float64) ([]float64, []float64, error) {
+	values := make([]float64, len(timestamps))
+	costs := make([]float64, len(timestamps))
+	holdingsMap := make(map[string]*Holding)
+	next := 0
+	for i, timestamp := range timestamps {
+		date := time.Unix(0, int64(timestamp)*int64(time.Millisecond))
+		for next < len(transactions) && !transactions[next].Date.After(date) {
+			err := applyTransaction(holdingsMap, transactions[next])
+			if err != nil {
+				return nil, nil, err
+			}
+			next++
+		}
+
+		value, cost := decimal.Zero, decimal.Zero
+		for c, crypto := range cryptos {
+			holding, found := holdingsMap[crypto]
+			if !found {
+				continue
+			}
+			value = value.Add(holding.Quantity.Mul(decimal.NewFromFloat(prices[c][i])))
+			cost = cost.Add(holding.CostBasis)
+		}
+		values[i] = value.Float64()
+		costs[i] = cost.Float64()
+	}
+	return values, costs, nil
+}
+
+// applyTransaction Updates the holding of the transaction crypto
+func applyTransaction(holdingsMap map[string]*Holding, transaction Transaction) error {
+	holding, found := holdingsMap[transaction.Crypto]
+	if !found {
+		holding = &Holding{Crypto: transaction.Crypto}
+		holdingsMap[transaction.Crypto] = holding
+	}
+	switch transaction.Side {
+	case Buy:
+		holding.Quantity = holding.Quantity.Add(transaction.Quantity)
+		holding.CostBasis = holding.CostBasis.Add(transaction.Quantity.Mul(transaction.Price))
+	case Sell:
+		if transaction.Quantity.GreaterThan(holding.Quantity) {
+			return fmt.Errorf("you can't sell %s %s, you only have %s", transaction.Quantity, transaction.Crypto, holding.Quantity)
+		}
+		soldCost := holding.AverageCost().Mul(transaction.Quantity)
+		holding.RealizedPnL = holding.RealizedPnL.Add(transaction.Quantity.Mul(transaction.Price).Sub(soldCost))
+		holding.CostBasis = holding.CostBasis.Sub(soldCost)
+		holding.Quantity = holding.Quantity.Sub(transaction.Quantity)
+	}
+	return nil
+}
+
+// formatTransaction Writes the transaction as user|date|side|crypto|quantity|price[|ref]
+func formatTransaction(transaction Transaction) string {
+	data := []string{
+		transaction.User,
+		transaction.Date.UTC().Format(time.RFC3339),
+		transaction.Side,
+		transaction.Crypto,
+		transaction.Quantity.String(),
+		transaction.Price.String(),
+	}
+	if transaction.Ref != "" {
+		data = append(data, transaction.Ref)
+	}
+	return strings.Join(data, "|")
+}
+
+func parseTransaction(line string) (Transaction, error) {
+	data := strings.Split(line, "|")
+	if len(data) < 6 {
+		return Transaction{}, fmt.Errorf("Error parsing the transaction")
+	}
+	date, err := time.Parse(time.RFC3339, data[1])
+	if err != nil {
+		return Transaction{}, fmt.Errorf("Error parsing the transaction date")
+	}
+	quantity, err := decimal.NewFromString(data[4])
+	if err != nil {
+		return Transaction{}, fmt.Errorf("Error parsing the transaction quantity")
+	}
+	price, err := decimal.NewFromString(data[5])
+	if err != nil {
+		return Transaction{}, fmt.Errorf("Error parsing the transaction price")
+	}
+	transaction := Transaction{
+		User:     data[0],
+		Date:     date,
+		Side:     data[2],
+		Crypto:   data[3],
+		Quantity: quantity,
+		Price:    price,
+	}
+	if len(data) > 6 {
+		transaction.Ref = data[6]
+	}
+	return transaction, nil
+}
@@ -1,14 +1,32 @@
 package utils
 
 import (
+	"bufio"
 	"fmt"
-	"github.com/slack-go/slack"
-	"log"
+	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
+const defaultRulesFileName = "alarms.txt"
+
+// Status of a rule in the rules file
+const (
+	ActiveRule = "Active"
+	// FiredRule The crypto went past the value and the rule was announced
+	FiredRule   = "Closed"
+	ExpiredRule = "Expired"
+	// UndeliverableRule The crypto went past the value but the announcement couldn't be posted, like when the bot
+	// was removed from the channel of the rule
+	UndeliverableRule = "Undeliverable"
+)
+
+// rulesMutex Guards the rules file, which is written by the commands, the alert modal and the rules checker
+var rulesMutex sync.Mutex
+
 const (
 	highLimit Rules = iota + 1
 	lowLimit
@@ -23,39 +41,246 @@ var (
 
 type Rules int
 
+// Rule A price alert of a user
+type Rule = register
+
 type register struct {
-	user   string
+	user string
+	// userID Slack ID of the user, empty for rules saved before it was kept
+	userID string
+	// date When the rule was created, in UTC. It also identifies the rules of a user
 	date   string
 	crypto string
 	price  float64
 	rule   Rules
+	// expires When the rule stops being checked (RFC3339), empty if it never expires
+	expires string
+	// channel Where the rule is announced, the alerts channel when empty
+	channel string
+	status  string
+	// closed When the rule fired or expired (RFC3339), empty while it is active
+	closed string
 }
 
+// LoadData Reads a user|date|crypto|price|rule[|expires|channel[|closed[|userID]]] line of the rules file, without its status
 func LoadData(data string) (*register, error) {
 	var success bool
-	dataSplited := strings.Split(data, "|")
+	dataSplited := strings.Split(strings.TrimSuffix(data, "\n"), "|")
+	if len(dataSplited) < 5 {
+		return nil, fmt.Errorf("Error parsing the rule")
+	}
 	crypto := dataSplited[2]
 	price, err := strconv.ParseFloat(dataSplited[3], 64)
 	if err != nil {
 		return nil, fmt.Errorf("Error parsing the price")
 	}
 
-	rule, success := parseStringToRule(strings.TrimSuffix(dataSplited[4], "\n"))
+	rule, success := parseStringToRule(dataSplited[4])
 	if !success {
 		return nil, fmt.Errorf("Error parsing the rule")
 	}
 	reg := newRegister(dataSplited[0], dataSplited[1], crypto, price, rule)
+	// Rules saved before the expiry and the delivery options have only five fields
+	if len(dataSplited) >= 7 {
+		reg.expires = dataSplited[5]
+		reg.channel = dataSplited[6]
+	}
+	if len(dataSplited) >= 8 {
+		reg.closed = dataSplited[7]
+	}
+	if len(dataSplited) >= 9 {
+		reg.userID = dataSplited[8]
+	}
 	return reg, nil
 }
 
-func IsPricePastBarrier(reg register, currentCryptoPrices map[string]float64) bool {
+// NewRule A rule of the user, identified by its creation date. expires and channel may be empty
+func NewRule(user string, userID string, date string, crypto string, price float64, rule Rules, expires string, channel string) *register {
+	reg := newRegister(user, date, crypto, price, rule)
+	reg.userID = userID
+	reg.expires = expires
+	reg.channel = channel
+	return reg
+}
+
+// RulesFileName The file where the rules are saved
+func RulesFileName() string {
+	if name := os.Getenv("ALARM_FILENAME"); name != "" {
+		return name
+	}
+	return defaultRulesFileName
+}
+
+// LoadActiveRules Reads every active rule saved in the rules file
+func LoadActiveRules(fileName string) ([]*register, error) {
+	rulesMutex.Lock()
+	defer rulesMutex.Unlock()
+	rules, err := loadRules(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var active []*register
+	for _, rule := range rules {
+		if rule.status == ActiveRule {
+			active = append(active, rule)
+		}
+	}
+	return active, nil
+}
+
+// LoadUserRules Reads every rule of the user, whatever its status, oldest first
+func LoadUserRules(user string) ([]*register, error) {
+	rulesMutex.Lock()
+	defer rulesMutex.Unlock()
+	rules, err := loadRules(RulesFileName())
+	if err != nil {
+		return nil, err
+	}
+	var userRules []*register
+	for _, rule := range rules {
+		if rule.user == user {
+			userRules = append(userRules, rule)
+		}
+	}
+	return userRules, nil
+}
+
+// GetActiveRule Finds the active rule of the user with that ID
+func GetActiveRule(user string, id string) (*register, error) {
+	rules, err := LoadActiveRules(RulesFileName())
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		if rule.user == user && rule.date == id {
+			return rule, nil
+		}
+	}
+	return nil, fmt.Errorf("That alert isn't yours, or it already fired or expired")
+}
+
+// SaveRule Adds the rule, or replaces the active rule of the user with the same ID. A rule edited after the original
+// one fired or expired is added with a new ID, so the closed one is kept apart
+func SaveRule(reg *register) error {
+	rulesMutex.Lock()
+	defer rulesMutex.Unlock()
+	rules, err := loadRules(RulesFileName())
+	if err != nil {
+		return err
+	}
+	reg.status = ActiveRule
+	replaced, closed := false, false
+	for i, rule := range rules {
+		if rule.user != reg.user || rule.date != reg.date {
+			continue
+		}
+		if rule.status == ActiveRule {
+			rules[i] = reg
+			replaced = true
+		} else {
+			closed = true
+		}
+	}
+	if !replaced {
+		if closed {
+			reg.date = time.Now().UTC().Format(time.RFC3339Nano)
+		}
+		rules = append(rules, reg)
+	}
+	return saveRules(RulesFileName(), rules)
+}
+
+// CloseRule Stops checking the active rule of the user with that ID, because it fired or expired at that moment
+func CloseRule(user string, id string, status string, at time.Time) error {
+	rulesMutex.Lock()
+	defer rulesMutex.Unlock()
+	rules, err := loadRules(RulesFileName())
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if rule.status == ActiveRule && rule.user == user && rule.date == id {
+			rule.status = status
+			rule.closed = at.UTC().Format(time.RFC3339)
+			return saveRules(RulesFileName(), rules)
+		}
+	}
+	return nil
+}
+
+// DeleteRule Removes the active rule of the user with that ID. Closed rules are kept, an edited rule may have had the
+// ID of one of them
+func DeleteRule(user string, id string) error {
+	rulesMutex.Lock()
+	defer rulesMutex.Unlock()
+	rules, err := loadRules(RulesFileName())
+	if err != nil {
+		return err
+	}
+	for i, rule := range rules {
+		if rule.status == ActiveRule && rule.user == user && rule.date == id {
+			return saveRules(RulesFileName(), append(rules[:i], rules[i+1:]...))
+		}
+	}
+	return fmt.Errorf("That alert isn't yours, or it already fired, expired or was deleted")
+}
+
+// loadRules Reads the status|user|date|crypto|price|rule|expires|channel|closed|userID lines of the rules file, the caller
+// holds rulesMutex
+func loadRules(fileName string) ([]*register, error) {
+	rulesFile, err := os.Open(fileName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rulesFile.Close()
+
+	var rules []*register
+	scanner := bufio.NewScanner(rulesFile)
+	for scanner.Scan() {
+		line := scanner.Text()
+		separator := strings.Index(line, "|")
+		if separator < 0 {
+			continue
+		}
+		reg, err := LoadData(line[separator+1:])
+		if err != nil {
+			return nil, err
+		}
+		reg.status = line[:separator]
+		rules = append(rules, reg)
+	}
+	return rules, scanner.Err()
+}
+
+// saveRules Writes the rules file, the caller holds rulesMutex
+func saveRules(fileName string, rules []*register) error {
+	var sb strings.Builder
+	for _, rule := range rules {
+		sb.WriteString(rule.status + "|" + rule.line() + "\n")
+	}
+	return ioutil.WriteFile(fileName, []byte(sb.String()), 0644)
+}
+
+// IsPricePastBarrier Tells if the crypto of the rule went past its value. The prices are fetched once per check and
+// kept in currentCryptoPrices, a rule whose price can't be fetched isn't past its value
+func IsPricePastBarrier(reg register, currentCryptoPrices map[string]float64) (bool, error) {
 
 	cryptoName := reg.crypto
 
 	price := 0.0
 
 	if !isValueSearched(cryptoName, currentCryptoPrices) {
-		price, _ = strconv.ParseFloat(GetCryptoValue(cryptoName, "USD"), 64)
+		value, err := GetCryptoValue(cryptoName, "USD")
+		if err != nil {
+			return false, err
+		}
+		price, err = strconv.ParseFloat(value, 64)
+		if err != nil {
+			return false, err
+		}
 		currentCryptoPrices[cryptoName] = price
 	} else {
 		price = currentCryptoPrices[cryptoName]
@@ -63,46 +288,20 @@ func IsPricePastBarrier(reg register, currentCryptoPrices map[string]float64) bo
 
 	if reg.rule == Rules(1) {
 		if reg.price < price {
-			return true
+			return true, nil
 		} else {
-			return false
+			return false, nil
 		}
 	}
 	if reg.rule == Rules(2) {
 		if price < reg.price {
-			return true
+			return true, nil
 		} else {
-			return false
+			return false, nil
 		}
 	}
 	// ... add Rules
-	return false
-}
-
-func PostMessageRule(register *register, api *slack.Client) error {
-	fields := []slack.AttachmentField{
-		{
-			Title: "Date",
-			Value: register.date,
-		}, {
-			Title: "Initializer",
-			Value: register.user,
-		},
-	}
-
-	text := fmt.Sprintf(register.rule.String()+": "+register.crypto+" has reached the value %f", register.price)
-	pretext := "As you requested!"
-	color := "#3aa030"
-
-	attachment := GetAttachment(text, pretext, color, fields, "")
-
-	_, _, err := api.PostMessage(os.Getenv("SLACK_CHANNEL_ID"), slack.MsgOptionAttachments(attachment))
-
-	if err != nil {
-		log.Println("Error posting message! ", err)
-		return err
-	}
-	return nil
+	return false, nil
 }
 
 func newRegister(user string, date string, crypto string, price float64, rule Rules) *register {
@@ -115,6 +314,80 @@ func newRegister(user string, date string, crypto string, price float64, rule Ru
 	return &reg
 }
 
+func (r *register) User() string {
+	return r.user
+}
+
+// UserID Slack ID of the user, empty for rules saved before it was kept
+func (r *register) UserID() string {
+	return r.userID
+}
+
+func (r *register) Crypto() string {
+	return r.crypto
+}
+
+func (r *register) Price() float64 {
+	return r.price
+}
+
+func (r *register) Rule() Rules {
+	return r.rule
+}
+
+// ID Identifies the rule among the ones of its user
+func (r *register) ID() string {
+	return r.date
+}
+
+// Channel Where the rule is announced, empty for the alerts channel
+func (r *register) Channel() string {
+	return r.channel
+}
+
+// Direct Tells if the rule is announced by direct message. Posting to a user ID sends it to the user's DM with the bot
+func (r *register) Direct() bool {
+	if r.channel == "" {
+		return false
+	}
+	// Slack user IDs start with U or W, and DM IDs with D
+	return r.channel == r.userID || strings.IndexByte("UWD", r.channel[0]) >= 0
+}
+
+// Status Whether the rule is active, fired or expired
+func (r *register) Status() string {
+	return r.status
+}
+
+// ClosedAt When the rule fired or expired, false while it is active
+func (r *register) ClosedAt() (time.Time, bool) {
+	if r.closed == "" {
+		return time.Time{}, false
+	}
+	closed, err := time.Parse(time.RFC3339, r.closed)
+	return closed, err == nil
+}
+
+// ExpiresAt When the rule stops being checked, false if it never expires
+func (r *register) ExpiresAt() (time.Time, bool) {
+	if r.expires == "" {
+		return time.Time{}, false
+	}
+	expires, err := time.Parse(time.RFC3339, r.expires)
+	return expires, err == nil
+}
+
+// Expired Tells if the rule stopped being checked at that moment
+func (r *register) Expired(now time.Time) bool {
+	expires, found := r.ExpiresAt()
+	return found && !now.Before(expires)
+}
+
+// line The rule as it is saved, without its status
+func (r *register) line() string {
+	return fmt.Sprintf("%s|%s|%s|%f|%s|%s|%s|%s|%s", r.user, r.date, r.crypto, r.price, r.rule, r.expires, r.channel, r.closed, r.userID)
+}
+
 func isValueSearched(crypto string, currentCryptoPrices map[string]float64) bool {
 	_, valueInMap := currentCryptoPrices[crypto]
 	return valueInMap
@@ -131,6 +404,11 @@ func (r Rules) String() string {
 	}
 }
 
+// ParseRule Reads a rule type, highLimit or lowLimit
+func ParseRule(str string) (Rules, bool) {
+	return parseStringToRule(str)
+}
+
 func parseStringToRule(str string) (Rules, bool) {
 	r, ok := rulesMap[strings.ToLower(str)]
 	return r, ok
@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FirstDate Oldest date we ask market data for, used by the max range (Bitcoin genesis block)
+var FirstDate = time.Date(2009, time.January, 3, 0, 0, 0, 0, time.UTC)
+
+// isoLayouts Accepted ISO datetimes, the timezone is optional
+var isoLayouts = []string{
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05Z07:00",
+}
+
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// durationUnit A unit of the durations, like the h of 24h
+type durationUnit struct {
+	suffix   string
+	duration time.Duration
+}
+
+// rangeUnits Units of the durations of the ranges, m is months and minutes are min. Months and years are counted on
+// the calendar by the ranges, the 30 and 365 days are only used out of them
+var rangeUnits = []durationUnit{
+	{"min", time.Minute},
+	{"s", time.Second},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+	{"m", 30 * 24 * time.Hour},
+	{"y", 365 * 24 * time.Hour},
+}
+
+// intervalUnits Units of the live prices and the export intervals, which are too short for months: m is minutes
+var intervalUnits = []durationUnit{
+	{"min", time.Minute},
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+}
+
+// ParseDuration Reads a duration of a range: 30s, 15min, 4h, 7d, 2w, 6m or 1y. m is months (30 days) and y years
+// (365 days)
+func ParseDuration(value string) (time.Duration, error) {
+	return parseUnits(value, rangeUnits, "30s, 15min, 4h, 7d, 2w, 6m or 1y")
+}
+
+// ParseInterval Reads the duration of a live price or an export interval: 30s, 15m (or 15min), 4h, 1d or 1w. m is
+// minutes
+func ParseInterval(value string) (time.Duration, error) {
+	return parseUnits(value, intervalUnits, "30s, 15m, 4h, 1d or 1w")
+}
+
+func parseUnits(value string, units []durationUnit, examples string) (time.Duration, error) {
+	amount, unit, err := splitDuration(value, units, examples)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(amount) * unit.duration, nil
+}
+
+// splitDuration Separates the amount of a duration from its unit, the amount must be a whole number greater than 0
+func splitDuration(value string, units []durationUnit, examples string) (int, durationUnit, error) {
+	value = strings.ToLower(value)
+	for _, unit := range units {
+		if !strings.HasSuffix(value, unit.suffix) {
+			continue
+		}
+		number := strings.TrimSuffix(value, unit.suffix)
+		amount, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, unit, fmt.Errorf("%q is not a valid duration: %q should be a whole number", value, number)
+		}
+		if amount <= 0 {
+			return 0, unit, fmt.Errorf("%q is not a valid duration: the amount must be greater than 0", value)
+		}
+		return amount, unit, nil
+	}
+	return 0, durationUnit{}, fmt.Errorf("%q is not a valid duration, try %s", value, examples)
+}
+
+// ParseTimeRange Reads a time range from the command arguments. Supported forms are:
+//   - a duration back from now (see ParseDuration): 24h, 7d, 2w, 6m, 1y, ytd or max
+//   - two dates: 01-03-2022 05-03-2022, 2022-03-01 2022-03-05T12:00, 2022-03-01T00:00-03:00 ...
+//   - since a date until now: since 01-03-2022
+//
+// Dates without time are taken at midnight in loc, and a date-only end is inclusive of that whole day
+func ParseTimeRange(args []string, now time.Time, loc *time.Location) (TimeRange, error) {
+	now = now.In(loc)
+	switch {
+	case len(args) == 0:
+		return TimeRange{}, fmt.Errorf("you didn't enter a time range, try 24h, 30d, 1y, ytd, max or two dates like 01-03-2022 05-03-2022")
+	case len(args) == 1:
+		return parseDurationRange(args[0], now, loc)
+	case len(args) == 2 && strings.ToLower(args[0]) == "since":
+		from, _, err := ParseDate(args[1], loc)
+		if err != nil {
+			return TimeRange{}, err
+		}
+		return newTimeRange(from, now, now)
+	case len(args) == 2:
+		from, _, err := ParseDate(args[0], loc)
+		if err != nil {
+			return TimeRange{}, err
+		}
+		to, dateOnly, err := ParseDate(args[1], loc)
+		if err != nil {
+			return TimeRange{}, err
+		}
+		if dateOnly && !from.After(to) {
+			to = to.AddDate(0, 0, 1)
+			if to.After(now) {
+				to = now
+			}
+		}
+		return newTimeRange(from, to, now)
+	default:
+		return TimeRange{}, fmt.Errorf("too many arguments for a time range: %q", strings.Join(args, " "))
+	}
+}
+
+// ParseDate Parses a DD-MM-YYYY date, an ISO date or an ISO datetime, telling whether it had no time of the day
+func ParseDate(value string, loc *time.Location) (time.Time, bool, error) {
+	value = strings.ToUpper(value)
+	switch {
+	case len(value) == len(Layout) && value[2] == '-':
+		date, err := time.ParseInLocation(Layout, value, loc)
+		return date, true, dateError(value, "DD-MM-YYYY", err)
+	case len(value) == len("2006-01-02") && value[4] == '-':
+		date, err := time.ParseInLocation("2006-01-02", value, loc)
+		return date, true, dateError(value, "YYYY-MM-DD", err)
+	case len(value) > len("2006-01-02") && value[4] == '-':
+		var err error
+		for _, layout := range isoLayouts {
+			var date time.Time
+			date, err = time.ParseInLocation(layout, value, loc)
+			if err == nil {
+				return date, false, nil
+			}
+		}
+		return time.Time{}, false, dateError(value, "YYYY-MM-DDTHH:MM[:SS] with an optional timezone like Z or -03:00", err)
+	default:
+		return time.Time{}, false, fmt.Errorf("%q is not a date I understand, use DD-MM-YYYY, YYYY-MM-DD or YYYY-MM-DDTHH:MM", strings.ToLower(value))
+	}
+}
+
+func dateError(value string, format string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if pe, ok := err.(*time.ParseError); ok && pe.Message != "" {
+		// Range errors come as ": day out of range"
+		return fmt.Errorf("%q is not a valid date%s", strings.ToLower(value), pe.Message)
+	}
+	return fmt.Errorf("%q is not a valid date, expected %s", strings.ToLower(value), format)
+}
+
+func parseDurationRange(value string, now time.Time, loc *time.Location) (TimeRange, error) {
+	value = strings.ToLower(value)
+	switch value {
+	case "ytd":
+		return newTimeRange(time.Date(now.Year(), time.January, 1, 0, 0, 0, 0, loc), now, now)
+	case "max":
+		return newTimeRange(FirstDate, now, now)
+	}
+
+	amount, unit, err := splitDuration(value, rangeUnits, "24h, 30d, 6m or 1y")
+	if err != nil {
+		return TimeRange{}, fmt.Errorf("%v. Ranges can also be ytd or max", err)
+	}
+
+	// Days, months and years are counted back on the calendar of loc
+	var from time.Time
+	switch unit.suffix {
+	case "d":
+		from = now.AddDate(0, 0, -amount)
+	case "w":
+		from = now.AddDate(0, 0, -7*amount)
+	case "m":
+		from = now.AddDate(0, -amount, 0)
+	case "y":
+		from = now.AddDate(-amount, 0, 0)
+	default:
+		from = now.Add(-time.Duration(amount) * unit.duration)
+	}
+	if from.Before(FirstDate) {
+		from = FirstDate
+	}
+	return newTimeRange(from, now, now)
+}
+
+func newTimeRange(from time.Time, to time.Time, now time.Time) (TimeRange, error) {
+	if from.After(now) {
+		return TimeRange{}, fmt.Errorf("the start date %s is in the future", from.Format(Layout))
+	}
+	if !from.Before(to) {
+		return TimeRange{}, fmt.Errorf("the start date %s must be before the end date %s", from.Format(Layout), to.Format(Layout))
+	}
+	return TimeRange{From: from, To: to}, nil
+}
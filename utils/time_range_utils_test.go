@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestMinutesAndMonths(t *testing.T) {
+	interval, err := ParseInterval("15m")
+	if err != nil || interval != 15*time.Minute {
+		t.Errorf("ParseInterval(15m) = %v, %v, want 15m0s", interval, err)
+	}
+
+	now := time.Date(2022, time.May, 10, 12, 0, 0, 0, time.UTC)
+	timeRange, err := ParseTimeRange([]string{"15m"}, now, time.UTC)
+	want := time.Date(2021, time.February, 10, 12, 0, 0, 0, time.UTC)
+	if err != nil || !timeRange.From.Equal(want) {
+		t.Errorf("ParseTimeRange(15m) starts on %v, %v, want %v", timeRange.From, err, want)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   string
+	}{
+		{value: "30s", want: 30 * time.Second},
+		{value: "15min", want: 15 * time.Minute},
+		{value: "4h", want: 4 * time.Hour},
+		{value: "7d", want: 7 * 24 * time.Hour},
+		{value: "2w", want: 14 * 24 * time.Hour},
+		{value: "6m", want: 180 * 24 * time.Hour},
+		{value: "1y", want: 365 * 24 * time.Hour},
+		{value: "4H", want: 4 * time.Hour},
+		{value: "0d", err: `"0d" is not a valid duration: the amount must be greater than 0`},
+		{value: "-3d", err: `"-3d" is not a valid duration: the amount must be greater than 0`},
+		{value: "1.5h", err: `"1.5h" is not a valid duration: "1.5" should be a whole number`},
+		{value: "h", err: `"h" is not a valid duration: "" should be a whole number`},
+		{value: "3q", err: `"3q" is not a valid duration, try 30s, 15min, 4h, 7d, 2w, 6m or 1y`},
+		{value: "", err: `"" is not a valid duration, try 30s, 15min, 4h, 7d, 2w, 6m or 1y`},
+	}
+	for _, test := range tests {
+		got, err := ParseDuration(test.value)
+		checkError(t, "ParseDuration("+test.value+")", err, test.err)
+		if err == nil && got != test.want {
+			t.Errorf("ParseDuration(%s) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   string
+	}{
+		{value: "10s", want: 10 * time.Second},
+		{value: "15m", want: 15 * time.Minute},
+		{value: "15min", want: 15 * time.Minute},
+		{value: "4h", want: 4 * time.Hour},
+		{value: "1d", want: 24 * time.Hour},
+		{value: "1w", want: 7 * 24 * time.Hour},
+		{value: "0s", err: `"0s" is not a valid duration: the amount must be greater than 0`},
+		{value: "1y", err: `"1y" is not a valid duration, try 30s, 15m, 4h, 1d or 1w`},
+	}
+	for _, test := range tests {
+		got, err := ParseInterval(test.value)
+		checkError(t, "ParseInterval("+test.value+")", err, test.err)
+		if err == nil && got != test.want {
+			t.Errorf("ParseInterval(%s) = %v, want %v", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tokyo := time.FixedZone("JST", 9*3600)
+	tests := []struct {
+		value    string
+		want     time.Time
+		dateOnly bool
+		err      string
+	}{
+		{value: "01-03-2022", want: time.Date(2022, time.March, 1, 0, 0, 0, 0, tokyo), dateOnly: true},
+		{value: "2022-03-01", want: time.Date(2022, time.March, 1, 0, 0, 0, 0, tokyo), dateOnly: true},
+		{value: "2022-03-01T12:30", want: time.Date(2022, time.March, 1, 12, 30, 0, 0, tokyo)},
+		{value: "2022-03-01t12:30:15", want: time.Date(2022, time.March, 1, 12, 30, 15, 0, tokyo)},
+		{value: "2022-03-01T12:30Z", want: time.Date(2022, time.March, 1, 12, 30, 0, 0, time.UTC)},
+		{value: "2022-03-01T12:30:15-03:00", want: time.Date(2022, time.March, 1, 15, 30, 15, 0, time.UTC)},
+		{value: "31-02-2022", err: `"31-02-2022" is not a valid date: day out of range`},
+		{value: "01-13-2022", err: `"01-13-2022" is not a valid date: month out of range`},
+		{value: "2022-02-30", err: `"2022-02-30" is not a valid date: day out of range`},
+		{value: "2022-03-01 12:30", err: `"2022-03-01 12:30" is not a valid date, expected YYYY-MM-DDTHH:MM[:SS] with an optional timezone like Z or -03:00`},
+		{value: "01/03/2022", err: `"01/03/2022" is not a date I understand, use DD-MM-YYYY, YYYY-MM-DD or YYYY-MM-DDTHH:MM`},
+		{value: "yesterday", err: `"yesterday" is not a date I understand, use DD-MM-YYYY, YYYY-MM-DD or YYYY-MM-DDTHH:MM`},
+	}
+	for _, test := range tests {
+		got, dateOnly, err := ParseDate(test.value, tokyo)
+		checkError(t, "ParseDate("+test.value+")", err, test.err)
+		if err != nil {
+			continue
+		}
+		if !got.Equal(test.want) || dateOnly != test.dateOnly {
+			t.Errorf("ParseDate(%s) = %v, %v, want %v, %v", test.value, got, dateOnly, test.want, test.dateOnly)
+		}
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	now := time.Date(2022, time.May, 10, 12, 0, 0, 0, time.UTC)
+	day := func(month time.Month, day int) time.Time {
+		return time.Date(2022, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		args []string
+		from time.Time
+		to   time.Time
+		err  string
+	}{
+		{args: []string{"24h"}, from: now.Add(-24 * time.Hour), to: now},
+		{args: []string{"90min"}, from: now.Add(-90 * time.Minute), to: now},
+		{args: []string{"30d"}, from: now.AddDate(0, 0, -30), to: now},
+		{args: []string{"2w"}, from: now.AddDate(0, 0, -14), to: now},
+		{args: []string{"6m"}, from: now.AddDate(0, -6, 0), to: now},
+		{args: []string{"1y"}, from: now.AddDate(-1, 0, 0), to: now},
+		{args: []string{"YTD"}, from: day(time.January, 1), to: now},
+		{args: []string{"max"}, from: FirstDate, to: now},
+		{args: []string{"100y"}, from: FirstDate, to: now},
+		{args: []string{"since", "01-03-2022"}, from: day(time.March, 1), to: now},
+		{args: []string{"01-03-2022", "05-03-2022"}, from: day(time.March, 1), to: day(time.March, 6)},
+		{args: []string{"01-03-2022", "01-03-2022"}, from: day(time.March, 1), to: day(time.March, 2)},
+		{args: []string{"2022-05-01", "2022-05-10"}, from: day(time.May, 1), to: now},
+		{args: []string{"2022-03-01T06:00", "2022-03-01T18:00"}, from: day(time.March, 1).Add(6 * time.Hour), to: day(time.March, 1).Add(18 * time.Hour)},
+		{args: nil, err: "you didn't enter a time range, try 24h, 30d, 1y, ytd, max or two dates like 01-03-2022 05-03-2022"},
+		{args: []string{"0d"}, err: `"0d" is not a valid duration: the amount must be greater than 0. Ranges can also be ytd or max`},
+		{args: []string{"-1y"}, err: `"-1y" is not a valid duration: the amount must be greater than 0. Ranges can also be ytd or max`},
+		{args: []string{"week"}, err: `"week" is not a valid duration, try 24h, 30d, 6m or 1y. Ranges can also be ytd or max`},
+		{args: []string{"05-03-2022", "01-03-2022"}, err: "the start date 05-03-2022 must be before the end date 01-03-2022"},
+		{args: []string{"since", "01-01-2030"}, err: "the start date 01-01-2030 is in the future"},
+		{args: []string{"01-01-2030", "05-01-2030"}, err: "the start date 01-01-2030 is in the future"},
+		{args: []string{"01-03-2022", "32-03-2022"}, err: `"32-03-2022" is not a valid date: day out of range`},
+		{args: []string{"01-03-2022", "05-03-2022", "utc"}, err: `too many arguments for a time range: "01-03-2022 05-03-2022 utc"`},
+	}
+	for _, test := range tests {
+		name := fmt.Sprintf("ParseTimeRange(%q)", test.args)
+		got, err := ParseTimeRange(test.args, now, time.UTC)
+		checkError(t, name, err, test.err)
+		if err != nil {
+			continue
+		}
+		if !got.From.Equal(test.from) || !got.To.Equal(test.to) {
+			t.Errorf("%s = %v - %v, want %v - %v", name, got.From, got.To, test.from, test.to)
+		}
+	}
+}
+
+// checkError Fails unless err has the message want, or is nil when want is empty
+func checkError(t *testing.T, name string, err error, want string) {
+	t.Helper()
+	switch {
+	case want == "" && err != nil:
+		t.Errorf("%s failed: %v", name, err)
+	case want != "" && err == nil:
+		t.Errorf("%s = no error, want %q", name, want)
+	case want != "" && err.Error() != want:
+		t.Errorf("%s error = %q, want %q", name, err.Error(), want)
+	}
+}
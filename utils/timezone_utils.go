@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultTimezonesFileName = "timezones.txt"
+
+// timezonesMutex Guards the timezones file, since preferences are read and written from several goroutines
+var timezonesMutex sync.Mutex
+
+// DefaultLocation Bot timezone, taken from BOT_TIMEZONE (like America/Argentina/Buenos_Aires) or the server one
+func DefaultLocation() *time.Location {
+	name := os.Getenv("BOT_TIMEZONE")
+	if name == "" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		fmt.Println("Invalid BOT_TIMEZONE, using the server timezone: ", err)
+		return time.Local
+	}
+	return loc
+}
+
+// UserLocation Timezone of the user: their saved preference, then their Slack profile one (profileTZ, may be empty)
+// and lastly the bot default
+func UserLocation(userName string, profileTZ string) *time.Location {
+	if name, found := GetUserTimezone(userName); found {
+		if loc, err := time.LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	if profileTZ != "" {
+		if loc, err := time.LoadLocation(profileTZ); err == nil {
+			return loc
+		}
+	}
+	return DefaultLocation()
+}
+
+// GetUserTimezone Gets the timezone saved by the user, if any
+func GetUserTimezone(userName string) (string, bool) {
+	timezonesMutex.Lock()
+	defer timezonesMutex.Unlock()
+	timezones, err := loadTimezones()
+	if err != nil {
+		return "", false
+	}
+	name, found := timezones[userName]
+	return name, found
+}
+
+// SetUserTimezone Saves the user timezone preference, it must be an IANA name like Europe/Madrid
+func SetUserTimezone(userName string, name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "" || strings.EqualFold(name, "local") {
+		return nil, fmt.Errorf("%q is not a timezone I know, use a name like America/New_York or Europe/Madrid", name)
+	}
+	timezonesMutex.Lock()
+	defer timezonesMutex.Unlock()
+	timezones, err := loadTimezones()
+	if err != nil {
+		return nil, err
+	}
+	timezones[userName] = loc.String()
+	return loc, saveTimezones(timezones)
+}
+
+// ResetUserTimezone Forgets the user timezone preference
+func ResetUserTimezone(userName string) error {
+	timezonesMutex.Lock()
+	defer timezonesMutex.Unlock()
+	timezones, err := loadTimezones()
+	if err != nil {
+		return err
+	}
+	delete(timezones, userName)
+	return saveTimezones(timezones)
+}
+
+// zoneinfoSources Where the timezone database may be, the same places time.LoadLocation looks at
+var zoneinfoSources = []string{
+	"/usr/share/zoneinfo",
+	"/usr/share/lib/zoneinfo",
+	"/usr/lib/locale/TZ",
+	filepath.Join(runtime.GOROOT(), "lib", "time", "zoneinfo.zip"),
+}
+
+var (
+	timezoneNamesOnce sync.Once
+	// timezoneNames IANA names by their lowered version
+	timezoneNames map[string]string
+)
+
+// TimezoneName Restores the case of a timezone name that was lowered with the rest of the mention text, by looking
+// it up in the timezone database: america/port-au-prince -> America/Port-au-Prince. Unknown names are returned as is
+func TimezoneName(name string) string {
+	timezoneNamesOnce.Do(loadTimezoneNames)
+	if known, found := timezoneNames[strings.ToLower(name)]; found {
+		return known
+	}
+	return name
+}
+
+// loadTimezoneNames Lists the zones of the first timezone database found
+func loadTimezoneNames() {
+	timezoneNames = map[string]string{"utc": "UTC"}
+	sources := zoneinfoSources
+	if zoneinfo := os.Getenv("ZONEINFO"); zoneinfo != "" {
+		sources = append([]string{zoneinfo}, sources...)
+	}
+	for _, source := range sources {
+		names, err := listZones(source)
+		if err != nil || len(names) == 0 {
+			continue
+		}
+		for _, name := range names {
+			timezoneNames[strings.ToLower(name)] = name
+		}
+		return
+	}
+	fmt.Println("Timezone database not found, timezone names must be written with their case")
+}
+
+// listZones Names of the zones of a zoneinfo directory or zip
+func listZones(source string) ([]string, error) {
+	if strings.HasSuffix(source, ".zip") {
+		archive, err := zip.OpenReader(source)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+		var names []string
+		for _, file := range archive.File {
+			if !file.FileInfo().IsDir() {
+				names = append(names, file.Name)
+			}
+		}
+		return names, nil
+	}
+
+	var names []string
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		name, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(name))
+		return nil
+	})
+	return names, err
+}
+
+// FormatDate Formats the date in loc, with the timezone abbreviation
+func FormatDate(t time.Time, loc *time.Location) string {
+	return t.In(loc).Format("2006-01-02 15:04:05 MST")
+}
+
+// FormatRuleDate Shows the date a rule was saved with in loc. Rules saved before timezones were supported keep their text
+func FormatRuleDate(date string, loc *time.Location) string {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return date
+	}
+	return FormatDate(t, loc)
+}
+
+func timezonesFileName() string {
+	if name := os.Getenv("TIMEZONES_FILENAME"); name != "" {
+		return name
+	}
+	return defaultTimezonesFileName
+}
+
+// loadTimezones Reads the user|timezone lines of the timezones file
+func loadTimezones() (map[string]string, error) {
+	timezones := make(map[string]string)
+	timezonesFile, err := os.Open(timezonesFileName())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return timezones, nil
+		}
+		return nil, err
+	}
+	defer timezonesFile.Close()
+
+	scanner := bufio.NewScanner(timezonesFile)
+	for scanner.Scan() {
+		data := strings.Split(scanner.Text(), "|")
+		if len(data) == 2 {
+			timezones[data[0]] = data[1]
+		}
+	}
+	return timezones, scanner.Err()
+}
+
+func saveTimezones(timezones map[string]string) error {
+	var sb strings.Builder
+	for user, name := range timezones {
+		sb.WriteString(user + "|" + name + "\n")
+	}
+	return ioutil.WriteFile(timezonesFileName(), []byte(sb.String()), 0644)
+}
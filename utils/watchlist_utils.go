@@ -0,0 +1,259 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultWatchlistsFileName = "watchlists.txt"
+
+const (
+	UserWatchlist    = "user"
+	ChannelWatchlist = "channel"
+)
+
+// DigestLayout Format of the daily digest time
+const DigestLayout = "15:04"
+
+// watchlistsMutex Guards the watchlists file, which is written by the commands and by the digests scheduler
+var watchlistsMutex sync.Mutex
+
+// Watchlist Cryptos followed by a user or a channel, with its optional daily digest
+type Watchlist struct {
+	Kind      string
+	OwnerID   string
+	OwnerName string
+	Cryptos   []string
+	// DigestTime Time of the day (HH:MM) the digest is posted in Timezone, empty when there is no digest
+	DigestTime string
+	Timezone   string
+	// NextRun When the next digest is due, kept in the file so a restart doesn't lose or repeat it
+	NextRun time.Time
+}
+
+// Location Timezone of the digest
+func (w *Watchlist) Location() *time.Location {
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return DefaultLocation()
+	}
+	return loc
+}
+
+// ScheduleNextRun Sets NextRun to the first digest time after the given moment
+func (w *Watchlist) ScheduleNextRun(after time.Time) error {
+	if w.DigestTime == "" {
+		w.NextRun = time.Time{}
+		return nil
+	}
+	clock, err := time.Parse(DigestLayout, w.DigestTime)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid time, use HH:MM like 09:00", w.DigestTime)
+	}
+	local := after.In(w.Location())
+	next := time.Date(local.Year(), local.Month(), local.Day(), clock.Hour(), clock.Minute(), 0, 0, w.Location())
+	if !next.After(after) {
+		next = time.Date(local.Year(), local.Month(), local.Day()+1, clock.Hour(), clock.Minute(), 0, 0, w.Location())
+	}
+	w.NextRun = next
+	return nil
+}
+
+func watchlistsFileName() string {
+	if name := os.Getenv("WATCHLISTS_FILENAME"); name != "" {
+		return name
+	}
+	return defaultWatchlistsFileName
+}
+
+// LoadWatchlists Reads every watchlist
+func LoadWatchlists() ([]*Watchlist, error) {
+	watchlistsMutex.Lock()
+	defer watchlistsMutex.Unlock()
+	return loadWatchlists()
+}
+
+// GetWatchlist Gets the watchlist of the owner, or a new empty one if it has none
+func GetWatchlist(kind string, ownerID string, ownerName string) (*Watchlist, error) {
+	watchlistsMutex.Lock()
+	defer watchlistsMutex.Unlock()
+	watchlists, err := loadWatchlists()
+	if err != nil {
+		return nil, err
+	}
+	for _, watchlist := range watchlists {
+		if watchlist.Kind == kind && watchlist.OwnerID == ownerID {
+			return watchlist, nil
+		}
+	}
+	return &Watchlist{Kind: kind, OwnerID: ownerID, OwnerName: ownerName}, nil
+}
+
+// UpdateWatchlist Changes the watchlist of the owner with update, creating it if it has none, and saves it. Reading,
+// changing and saving happen under the same lock, so concurrent changes aren't lost. Nothing is saved if update fails
+func UpdateWatchlist(kind string, ownerID string, ownerName string, update func(watchlist *Watchlist) error) (*Watchlist, error) {
+	watchlistsMutex.Lock()
+	defer watchlistsMutex.Unlock()
+	watchlists, err := loadWatchlists()
+	if err != nil {
+		return nil, err
+	}
+	var watchlist *Watchlist
+	for _, existing := range watchlists {
+		if existing.Kind == kind && existing.OwnerID == ownerID {
+			watchlist = existing
+		}
+	}
+	if watchlist == nil {
+		watchlist = &Watchlist{Kind: kind, OwnerID: ownerID, OwnerName: ownerName}
+		watchlists = append(watchlists, watchlist)
+	}
+	err = update(watchlist)
+	if err != nil {
+		return nil, err
+	}
+	return watchlist, saveWatchlists(watchlists)
+}
+
+// AddCryptos Appends the cryptos that aren't in the watchlist yet
+func (w *Watchlist) AddCryptos(cryptos []string) {
+	for _, crypto := range cryptos {
+		if !w.Watches(crypto) {
+			w.Cryptos = append(w.Cryptos, crypto)
+		}
+	}
+}
+
+// RemoveCryptos Takes the cryptos out of the watchlist
+func (w *Watchlist) RemoveCryptos(cryptos []string) {
+	for _, crypto := range cryptos {
+		var kept []string
+		for _, existing := range w.Cryptos {
+			if existing != crypto {
+				kept = append(kept, existing)
+			}
+		}
+		w.Cryptos = kept
+	}
+}
+
+// Watches Tells if the crypto is in the watchlist
+func (w *Watchlist) Watches(crypto string) bool {
+	for _, existing := range w.Cryptos {
+		if existing == crypto {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduleNextDigest Moves the next digest of the owner's watchlist after the given moment. The watchlist is read
+// again, so changes made while its digest was being posted are kept. Watchlists without a digest are left as they are
+func ScheduleNextDigest(kind string, ownerID string, after time.Time) error {
+	watchlistsMutex.Lock()
+	defer watchlistsMutex.Unlock()
+	watchlists, err := loadWatchlists()
+	if err != nil {
+		return err
+	}
+	for _, watchlist := range watchlists {
+		if watchlist.Kind == kind && watchlist.OwnerID == ownerID {
+			if watchlist.DigestTime == "" {
+				return nil
+			}
+			err = watchlist.ScheduleNextRun(after)
+			if err != nil {
+				return err
+			}
+			return saveWatchlists(watchlists)
+		}
+	}
+	return nil
+}
+
+// Sparkline Draws the values with block characters, like ▁▂▄▆█
+func Sparkline(values []float64) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	if len(values) == 0 {
+		return ""
+	}
+	min, max := values[0], values[0]
+	for _, v := range values {
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	var sb strings.Builder
+	for _, v := range values {
+		index := 0
+		if max > min {
+			index = int((v - min) / (max - min) * float64(len(blocks)-1))
+		}
+		sb.WriteRune(blocks[index])
+	}
+	return sb.String()
+}
+
+// loadWatchlists Reads the kind|ownerID|ownerName|cryptos|digestTime|timezone|nextRun lines of the watchlists file
+func loadWatchlists() ([]*Watchlist, error) {
+	watchlistsFile, err := os.Open(watchlistsFileName())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer watchlistsFile.Close()
+
+	var watchlists []*Watchlist
+	scanner := bufio.NewScanner(watchlistsFile)
+	for scanner.Scan() {
+		data := strings.Split(scanner.Text(), "|")
+		if len(data) != 7 {
+			continue
+		}
+		watchlist := &Watchlist{
+			Kind:       data[0],
+			OwnerID:    data[1],
+			OwnerName:  data[2],
+			DigestTime: data[4],
+			Timezone:   data[5],
+		}
+		if data[3] != "" {
+			watchlist.Cryptos = strings.Split(data[3], ",")
+		}
+		if data[6] != "" {
+			watchlist.NextRun, err = time.Parse(time.RFC3339, data[6])
+			if err != nil {
+				return nil, fmt.Errorf("Error parsing the next digest of %s", watchlist.OwnerName)
+			}
+		}
+		watchlists = append(watchlists, watchlist)
+	}
+	return watchlists, scanner.Err()
+}
+
+func saveWatchlists(watchlists []*Watchlist) error {
+	var sb strings.Builder
+	for _, watchlist := range watchlists {
+		nextRun := ""
+		if !watchlist.NextRun.IsZero() {
+			nextRun = watchlist.NextRun.UTC().Format(time.RFC3339)
+		}
+		sb.WriteString(strings.Join([]string{
+			watchlist.Kind,
+			watchlist.OwnerID,
+			watchlist.OwnerName,
+			strings.Join(watchlist.Cryptos, ","),
+			watchlist.DigestTime,
+			watchlist.Timezone,
+			nextRun,
+		}, "|") + "\n")
+	}
+	return ioutil.WriteFile(watchlistsFileName(), []byte(sb.String()), 0644)
+}